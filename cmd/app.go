@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"fifthOne/cmd/buildCFG"
+	"fifthOne/internal/health"
+	"fifthOne/internal/migrator"
+	"fifthOne/internal/notify"
+	"fifthOne/internal/payment"
+	"fifthOne/internal/payment/fake"
+	"fifthOne/internal/rabbit"
+	"fifthOne/internal/repo"
+	"fifthOne/internal/scheduler"
+
+	"github.com/rs/zerolog"
+	"github.com/wb-go/wbf/config"
+	"github.com/wb-go/wbf/dbpg"
+)
+
+const defaultConfigPath = "config.yaml"
+
+type app struct {
+	cfg *config.Config
+	log *zerolog.Logger
+	db  *dbpg.DB
+}
+
+func newApp(log *zerolog.Logger) (*app, error) {
+	configPath := os.Getenv("CONFIG_PATH")
+	if configPath == "" {
+		configPath = defaultConfigPath
+	}
+
+	cfg := config.New()
+	if err := cfg.Load(configPath, "", "'"); err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	masterDSN, slaveDSNs, poolOptions, err := buildCFG.BuildDBConfig(cfg, log)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build DB config: %w", err)
+	}
+	db, err := dbpg.New(masterDSN, slaveDSNs, poolOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to DB: %w", err)
+	}
+	if err := db.Master.Ping(); err != nil {
+		return nil, fmt.Errorf("DB ping failed: %w", err)
+	}
+	log.Info().Msg("Database connected successfully")
+
+	return &app{cfg: cfg, log: log, db: db}, nil
+}
+
+// repository opens the repository; the notifications it queues link to publicURL, with the links
+// sealed by linkSecret.
+func (a *app) repository(publicURL, linkSecret string) (repo.Repository, error) {
+	repository, err := repo.NewRepository(a.db, a.log, notify.Outbox(publicURL, linkSecret))
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize repository: %w", err)
+	}
+	return repository, nil
+}
+
+func (a *app) migrator() (*migrator.Migrator, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("cannot get working directory: %w", err)
+	}
+	m, err := migrator.New(a.db.Master, filepath.Join(cwd, "migrations/postgres"), a.log)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load migrations: %w", err)
+	}
+	return m, nil
+}
+
+func (a *app) rabbit() (*rabbit.Client, error) {
+	rabbitCfg, err := buildCFG.BuildRabbitConfig(a.cfg, a.log)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load RabbitMQ config: %w", err)
+	}
+	rmq, err := rabbit.NewRabbit(rabbitCfg.Url, rabbitCfg.Exchange, rabbitCfg.Queue, rabbit.Options{
+		MaxAttempts: rabbitCfg.MaxAttempts,
+		RetryDelay:  rabbitCfg.RetryDelay,
+		Workers:     rabbitCfg.Workers,
+		Prefetch:    rabbitCfg.Prefetch,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+	}
+	return rmq, nil
+}
+
+// scheduler builds the configured expiry scheduler. The returned func releases its resources.
+func (a *app) scheduler(sc *buildCFG.SchedulerConfig) (scheduler.Scheduler, func(), error) {
+	switch sc.Kind {
+	case scheduler.KindRabbit:
+		rmq, err := a.rabbit()
+		if err != nil {
+			return nil, nil, err
+		}
+		return scheduler.NewRabbit(rmq, a.log), rmq.Close, nil
+	case scheduler.KindPostgres:
+		return scheduler.NewPostgres(a.db, a.log), func() {}, nil
+	case scheduler.KindMemory:
+		return scheduler.NewMemory(a.log), func() {}, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown scheduler kind %q", sc.Kind)
+	}
+}
+
+// healthChecker checks the database and, when the scheduler depends on a broker, its connection.
+func (a *app) healthChecker(sched scheduler.Scheduler) *health.Checker {
+	checker := health.New()
+	checker.Add("postgres", a.db.Master.PingContext)
+	if h, ok := sched.(interface{ Healthy() bool }); ok {
+		checker.Add("rabbitmq", func(context.Context) error {
+			if !h.Healthy() {
+				return rabbit.ErrNotConnected
+			}
+			return nil
+		})
+	}
+	return checker
+}
+
+// notifier builds a Notifier that sends through the configured email and sms channels, with
+// templates loaded from disk. The returned func releases the channels.
+func (a *app) notifier(nc *buildCFG.NotifyConfig) (notify.Notifier, func(), error) {
+	templates, err := notify.LoadTemplates(nc.TemplatesDir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	email, closeEmail, err := a.emailChannel(nc)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var sms notify.Channel
+	closeSMS := func() {}
+	switch nc.SMSChannel {
+	case notify.ChannelHTTP:
+		sms, err = notify.NewHTTPText(notify.HTTPTextConfig{URL: nc.SMSURL, Token: nc.SMSToken})
+		if err != nil {
+			closeEmail()
+			return nil, nil, fmt.Errorf("invalid sms configuration: %w", err)
+		}
+	case notify.ChannelFile:
+		file, err := notify.NewFile(nc.SMSFilePath)
+		if err != nil {
+			closeEmail()
+			return nil, nil, err
+		}
+		sms, closeSMS = file, func() { _ = file.Close() }
+	}
+
+	return notify.New(templates, email, sms, a.log), func() {
+		closeEmail()
+		closeSMS()
+	}, nil
+}
+
+func (a *app) emailChannel(nc *buildCFG.NotifyConfig) (notify.Channel, func(), error) {
+	switch nc.Channel {
+	case notify.ChannelSMTP:
+		channel, err := notify.NewSMTP(notify.SMTPConfig{
+			Host:     nc.SMTPHost,
+			Port:     nc.SMTPPort,
+			Username: nc.SMTPUsername,
+			Password: nc.SMTPPassword,
+			From:     nc.From,
+			TLS:      nc.SMTPTLS,
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid smtp configuration: %w", err)
+		}
+		return channel, func() {}, nil
+	case notify.ChannelFile:
+		channel, err := notify.NewFile(nc.FilePath)
+		if err != nil {
+			return nil, nil, err
+		}
+		return channel, func() { _ = channel.Close() }, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown notification channel %q", nc.Channel)
+	}
+}
+
+func (a *app) paymentProvider(pc *buildCFG.PaymentConfig) (payment.Provider, error) {
+	switch pc.Provider {
+	case "fake":
+		return fake.NewClient(pc.FakeURL, pc.WebhookSecret), nil
+	default:
+		return nil, fmt.Errorf("unknown payment provider %q", pc.Provider)
+	}
+}
+
+func (a *app) close() {
+	if err := a.db.Master.Close(); err != nil {
+		a.log.Error().Err(err).Msg("failed to close DB connection")
+	}
+}
@@ -1,6 +1,7 @@
 package buildCFG
 
 import (
+	"fifthOne/internal/auth"
 	"fmt"
 	"github.com/rs/zerolog"
 	"github.com/wb-go/wbf/config"
@@ -13,6 +14,7 @@ type ServerConfig struct {
 	Port         string
 	Name         string
 	WriteTimeout time.Duration
+	PublicURL    string
 }
 type RedisConfig struct {
 	Addr     string
@@ -30,12 +32,18 @@ func BuildServerConfig(cfg *config.Config, log *zerolog.Logger) ServerConfig {
 		log.Fatal().Msgf("invalid write_timeout value: %v", err)
 	}
 
+	publicURL := cfg.GetString("server.public_url")
+	if publicURL == "" {
+		publicURL = "http://localhost:" + port
+	}
+
 	log.Info().Msgf("Starting %s on port %s (timeout %s)", serverName, port, writeTimeout)
 
 	return ServerConfig{
 		Port:         port,
 		Name:         serverName,
 		WriteTimeout: writeTimeout,
+		PublicURL:    publicURL,
 	}
 }
 func BuildDBConfig(cfg *config.Config, log *zerolog.Logger) (string, []string, *dbpg.Options, error) {
@@ -117,6 +125,12 @@ type RabbitConfig struct {
 	Url      string
 	Exchange string
 	Queue    string
+	// MaxAttempts and RetryDelay control retries before a message is dead-lettered; zero means default.
+	MaxAttempts int
+	RetryDelay  time.Duration
+	// Workers and Prefetch size the consumer pool of the worker; zero means default.
+	Workers  int
+	Prefetch int
 }
 
 func BuildRabbitConfig(cfg *config.Config, log *zerolog.Logger) (*RabbitConfig, error) {
@@ -128,10 +142,212 @@ func BuildRabbitConfig(cfg *config.Config, log *zerolog.Logger) (*RabbitConfig,
 		return nil, fmt.Errorf("incomplete rabbitmq configuration")
 	}
 
+	var retryDelay time.Duration
+	if s := cfg.GetString("rabbitmq.retry_delay"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rabbitmq.retry_delay: %w", err)
+		}
+		retryDelay = d
+	}
+
 	log.Info().Msgf("RabbitMQ config loaded: url=%s, exchange=%s, queue=%s", url, exchange, queue)
 	return &RabbitConfig{
-		Url:      url,
-		Exchange: exchange,
-		Queue:    queue,
+		Url:         url,
+		Exchange:    exchange,
+		Queue:       queue,
+		MaxAttempts: cfg.GetInt("rabbitmq.max_attempts"),
+		RetryDelay:  retryDelay,
+		Workers:     cfg.GetInt("rabbitmq.workers"),
+		Prefetch:    cfg.GetInt("rabbitmq.prefetch"),
 	}, nil
 }
+
+type SchedulerConfig struct {
+	Kind string
+}
+
+func BuildSchedulerConfig(cfg *config.Config, log *zerolog.Logger) (*SchedulerConfig, error) {
+	sc := &SchedulerConfig{Kind: cfg.GetString("scheduler.kind")}
+	if sc.Kind == "" {
+		sc.Kind = "rabbit"
+	}
+	switch sc.Kind {
+	case "rabbit", "postgres", "memory":
+	default:
+		return nil, fmt.Errorf("unknown scheduler kind %q", sc.Kind)
+	}
+
+	log.Info().Msgf("Scheduler config loaded: kind=%s", sc.Kind)
+	return sc, nil
+}
+
+type NotifyConfig struct {
+	Channel      string
+	TemplatesDir string
+	From         string
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	SMTPTLS      string
+	// FilePath is where the file channel appends messages; empty means stdout.
+	FilePath string
+	// MaxAttempts is how often the dispatcher tries a notification; zero means default.
+	MaxAttempts int
+	// UnsubscribeSecret signs the unsubscribe links in messages.
+	UnsubscribeSecret string
+	// LinkSecret encrypts the confirmation links in queued notifications; it must not be kept in
+	// the database.
+	LinkSecret string
+	// SMSChannel delivers text messages to phones: "http", "file" or empty to not send them.
+	SMSChannel  string
+	SMSURL      string
+	SMSToken    string
+	SMSFilePath string
+}
+
+func BuildNotifyConfig(cfg *config.Config, log *zerolog.Logger) (*NotifyConfig, error) {
+	nc := &NotifyConfig{
+		Channel:           cfg.GetString("notify.channel"),
+		TemplatesDir:      cfg.GetString("notify.templates_dir"),
+		From:              cfg.GetString("notify.from"),
+		SMTPHost:          cfg.GetString("notify.smtp.host"),
+		SMTPPort:          cfg.GetInt("notify.smtp.port"),
+		SMTPUsername:      cfg.GetString("notify.smtp.username"),
+		SMTPPassword:      cfg.GetString("notify.smtp.password"),
+		SMTPTLS:           cfg.GetString("notify.smtp.tls"),
+		FilePath:          cfg.GetString("notify.file.path"),
+		MaxAttempts:       cfg.GetInt("notify.max_attempts"),
+		UnsubscribeSecret: cfg.GetString("notify.unsubscribe_secret"),
+		LinkSecret:        cfg.GetString("notify.link_secret"),
+		SMSChannel:        cfg.GetString("notify.sms.channel"),
+		SMSURL:            cfg.GetString("notify.sms.url"),
+		SMSToken:          cfg.GetString("notify.sms.token"),
+		SMSFilePath:       cfg.GetString("notify.sms.file_path"),
+	}
+	if nc.Channel == "" {
+		nc.Channel = "file"
+	}
+	if nc.TemplatesDir == "" {
+		nc.TemplatesDir = "templates/notifications"
+	}
+	if nc.UnsubscribeSecret == "" {
+		return nil, fmt.Errorf("notify.unsubscribe_secret is required")
+	}
+	if nc.LinkSecret == "" {
+		return nil, fmt.Errorf("notify.link_secret is required")
+	}
+	switch nc.Channel {
+	case "smtp":
+		if nc.SMTPHost == "" || nc.SMTPPort == 0 {
+			return nil, fmt.Errorf("notify.smtp.host and notify.smtp.port are required for the smtp channel")
+		}
+	case "file":
+	default:
+		return nil, fmt.Errorf("unknown notification channel %q", nc.Channel)
+	}
+	switch nc.SMSChannel {
+	case "http":
+		if nc.SMSURL == "" {
+			return nil, fmt.Errorf("notify.sms.url is required for the http sms channel")
+		}
+	case "file", "":
+	default:
+		return nil, fmt.Errorf("unknown sms channel %q", nc.SMSChannel)
+	}
+
+	log.Info().Msgf("Notify config loaded: channel=%s, sms=%s, templates=%s", nc.Channel, nc.SMSChannel, nc.TemplatesDir)
+	return nc, nil
+}
+
+type ReminderConfig struct {
+	// Offsets are how long before the start of an event reminders go out.
+	Offsets  []time.Duration
+	Interval time.Duration
+}
+
+func BuildReminderConfig(cfg *config.Config, log *zerolog.Logger) (*ReminderConfig, error) {
+	raw := cfg.GetStringSlice("reminders.offsets")
+	if len(raw) == 0 {
+		raw = []string{"24h", "1h"}
+	}
+
+	rc := &ReminderConfig{}
+	for _, s := range raw {
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid reminder offset %q", s)
+		}
+		rc.Offsets = append(rc.Offsets, d)
+	}
+	if s := cfg.GetString("reminders.interval"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid reminders.interval: %w", err)
+		}
+		rc.Interval = d
+	}
+
+	log.Info().Msgf("Reminder config loaded: offsets=%v", rc.Offsets)
+	return rc, nil
+}
+
+type AuthConfig struct {
+	APIKeys []auth.APIKey `mapstructure:"api_keys"`
+}
+
+// BuildAuthConfig collects API keys from auth.api_keys. The legacy server.token is rejected
+// rather than silently turned into an admin key.
+func BuildAuthConfig(cfg *config.Config, log *zerolog.Logger) (*auth.KeyStore, error) {
+	var raw struct {
+		Auth AuthConfig `mapstructure:"auth"`
+	}
+	if err := cfg.Unmarshal(&raw); err != nil {
+		return nil, fmt.Errorf("invalid auth configuration: %w", err)
+	}
+
+	if cfg.GetString("server.token") != "" {
+		return nil, fmt.Errorf("server.token is no longer supported: configure an admin key in auth.api_keys instead")
+	}
+
+	keys := raw.Auth.APIKeys
+
+	store, err := auth.NewKeyStore(keys)
+	if err != nil {
+		return nil, fmt.Errorf("invalid auth configuration: %w", err)
+	}
+
+	log.Info().Msgf("Auth config loaded: %d api keys", len(keys))
+	return store, nil
+}
+
+type PaymentConfig struct {
+	Provider      string
+	WebhookSecret string
+	// FakeURL is where the fake provider client reaches the gateway API.
+	FakeURL string
+	// FakeServe mounts the fake gateway on the API server.
+	FakeServe bool
+	// FakeWebhookURL is where the fake gateway posts its notifications; empty means our own API.
+	FakeWebhookURL string
+}
+
+func BuildPaymentConfig(cfg *config.Config, log *zerolog.Logger) (*PaymentConfig, error) {
+	pc := &PaymentConfig{
+		Provider:       cfg.GetString("payment.provider"),
+		WebhookSecret:  cfg.GetString("payment.webhook_secret"),
+		FakeURL:        cfg.GetString("payment.fake.url"),
+		FakeServe:      cfg.GetBool("payment.fake.serve"),
+		FakeWebhookURL: cfg.GetString("payment.fake.webhook_url"),
+	}
+	if pc.Provider == "" {
+		pc.Provider = "fake"
+	}
+	if pc.Provider == "fake" && pc.FakeURL == "" {
+		return nil, fmt.Errorf("payment.fake.url is required for the fake provider")
+	}
+
+	log.Info().Msgf("Payment config loaded: provider=%s", pc.Provider)
+	return pc, nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"flag"
+
+	"fifthOne/internal/notify"
+
+	"github.com/rs/zerolog"
+)
+
+// runFakeSMTP serves a local SMTP server that logs and drops every message, so the smtp
+// notification channel can be tried without a real mail server.
+func runFakeSMTP(log *zerolog.Logger, args []string) error {
+	fs := flag.NewFlagSet("fakesmtp", flag.ContinueOnError)
+	addr := fs.String("addr", ":2525", "address to listen on")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	server, err := notify.ListenFakeSMTP(*addr, log)
+	if err != nil {
+		return err
+	}
+	log.Info().Msgf("Fake SMTP server listening on %s", server.Addr())
+
+	ctx, stop := signalContext()
+	defer stop()
+	<-ctx.Done()
+
+	return server.Close()
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"flag"
+
+	"fifthOne/internal/notify"
+
+	"github.com/rs/zerolog"
+)
+
+// runFakeText serves a local SMS/Telegram gateway that logs and drops every message, so the http
+// text channel can be tried without a real provider.
+func runFakeText(log *zerolog.Logger, args []string) error {
+	fs := flag.NewFlagSet("faketext", flag.ContinueOnError)
+	addr := fs.String("addr", ":2526", "address to listen on")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	server, err := notify.ListenFakeText(*addr, log)
+	if err != nil {
+		return err
+	}
+	log.Info().Msgf("Fake text gateway listening on %s", server.URL())
+
+	ctx, stop := signalContext()
+	defer stop()
+	<-ctx.Done()
+
+	return server.Close()
+}
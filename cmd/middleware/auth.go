@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"strings"
+
+	"fifthOne/internal/auth"
+	"fifthOne/internal/dto"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Authenticate resolves the API key from "Authorization: Bearer <key>" or "X-API-Key"
+// and stores the principal in the context. Requests without a key continue as anonymous attendees;
+// requests with an unknown key are rejected.
+func Authenticate(store *auth.KeyStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("X-API-Key")
+		if header := c.GetHeader("Authorization"); header != "" {
+			token, ok := strings.CutPrefix(header, "Bearer ")
+			if !ok {
+				dto.UnauthorizedError(c)
+				c.Abort()
+				return
+			}
+			key = strings.TrimSpace(token)
+		}
+
+		if key == "" {
+			auth.SetPrincipal(c, auth.Anonymous)
+			c.Next()
+			return
+		}
+
+		principal, ok := store.Lookup(key)
+		if !ok {
+			dto.UnauthorizedError(c)
+			c.Abort()
+			return
+		}
+
+		auth.SetPrincipal(c, principal)
+		c.Next()
+	}
+}
+
+func RequireRole(roles ...auth.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal := auth.FromContext(c)
+		if !principal.Authenticated() {
+			dto.UnauthorizedError(c)
+			c.Abort()
+			return
+		}
+		for _, role := range roles {
+			if principal.Role == role {
+				c.Next()
+				return
+			}
+		}
+		dto.ForbiddenError(c)
+		c.Abort()
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"fifthOne/internal/migrator"
+
+	"github.com/rs/zerolog"
+)
+
+func runMigrate(log *zerolog.Logger, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("migrate requires one of: up, down, status, force")
+	}
+	action, rest := args[0], args[1:]
+
+	a, err := newApp(log)
+	if err != nil {
+		return err
+	}
+	defer a.close()
+
+	m, err := a.migrator()
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	switch action {
+	case "up":
+		n, err := stepArg(rest, 0)
+		if err != nil {
+			return err
+		}
+		return m.Up(ctx, n)
+	case "down":
+		n, err := stepArg(rest, 1)
+		if err != nil {
+			return err
+		}
+		return m.Down(ctx, n)
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		return printStatus(statuses)
+	case "force":
+		if len(rest) != 1 {
+			return fmt.Errorf("migrate force requires a VERSION argument")
+		}
+		version, err := strconv.ParseInt(rest[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid version %q: %w", rest[0], err)
+		}
+		return m.Force(ctx, version)
+	default:
+		return fmt.Errorf("unknown migrate action %q", action)
+	}
+}
+
+func stepArg(args []string, def int) (int, error) {
+	if len(args) == 0 {
+		return def, nil
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid step count %q", args[0])
+	}
+	return n, nil
+}
+
+func printStatus(statuses []migrator.Status) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+	for _, st := range statuses {
+		appliedAt := "pending"
+		if st.Applied {
+			appliedAt = st.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", st.Version, st.Name, appliedAt)
+	}
+	return w.Flush()
+}
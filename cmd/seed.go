@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"time"
+
+	"fifthOne/cmd/buildCFG"
+	"fifthOne/internal/model"
+
+	"github.com/rs/zerolog"
+)
+
+func runSeed(log *zerolog.Logger, args []string) error {
+	fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+	force := fs.Bool("force", false, "insert demo events even if events already exist")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	a, err := newApp(log)
+	if err != nil {
+		return err
+	}
+	defer a.close()
+
+	serverCfg := buildCFG.BuildServerConfig(a.cfg, log)
+	notifyCfg, err := buildCFG.BuildNotifyConfig(a.cfg, log)
+	if err != nil {
+		return err
+	}
+	repository, err := a.repository(serverCfg.PublicURL, notifyCfg.LinkSecret)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	existing, err := repository.GetAllEvents(ctx)
+	if err != nil {
+		return err
+	}
+	if len(existing) > 0 && !*force {
+		log.Info().Int("events", len(existing)).Msg("events already exist, skipping seed (use -force to override)")
+		return nil
+	}
+
+	day := time.Now().Add(7 * 24 * time.Hour).Truncate(24 * time.Hour)
+	events := []model.Event{
+		{
+			Name:                  "TechConf",
+			Description:           "Ежегодная конференция по технологиям",
+			StartTime:             day.Add(10 * time.Hour),
+			EndTime:               day.Add(18 * time.Hour),
+			Location:              "Москва, Экспоцентр",
+			Capacity:              5,
+			PaymentTimeoutMinutes: 5,
+			Price:                 150000,
+			Currency:              "RUB",
+			RefundPolicy:          model.RefundFull,
+		},
+		{
+			Name:                  "Go Workshop",
+			Description:           "Мастер-класс по конкурентности в Go",
+			StartTime:             day.Add(34 * time.Hour),
+			EndTime:               day.Add(38 * time.Hour),
+			Location:              "Санкт-Петербург",
+			Capacity:              2,
+			PaymentTimeoutMinutes: 1,
+			Currency:              "RUB",
+			RefundPolicy:          model.RefundFull,
+		},
+	}
+
+	for i := range events {
+		id, err := repository.CreateEvent(ctx, &events[i])
+		if err != nil {
+			return err
+		}
+		log.Info().Int64("event_id", id).Str("name", events[i].Name).Msg("demo event created")
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"time"
+
+	"fifthOne/cmd/buildCFG"
+	"fifthOne/internal/api/api"
+	"fifthOne/internal/notify"
+	"fifthOne/internal/payment/fake"
+	"fifthOne/internal/rabbit"
+	"fifthOne/internal/scheduler"
+	"fifthOne/internal/service"
+
+	"github.com/rs/zerolog"
+)
+
+func runServe(log *zerolog.Logger, args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	migrate := fs.Bool("migrate", false, "apply pending migrations before starting")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	a, err := newApp(log)
+	if err != nil {
+		return err
+	}
+	defer a.close()
+
+	if *migrate {
+		m, err := a.migrator()
+		if err != nil {
+			return err
+		}
+		if err := m.Up(context.Background(), 0); err != nil {
+			return fmt.Errorf("migration failed: %w", err)
+		}
+	}
+
+	serverCfg := buildCFG.BuildServerConfig(a.cfg, log)
+
+	// notifications are only queued here and sent by the worker
+	notifyCfg, err := buildCFG.BuildNotifyConfig(a.cfg, log)
+	if err != nil {
+		return err
+	}
+
+	repository, err := a.repository(serverCfg.PublicURL, notifyCfg.LinkSecret)
+	if err != nil {
+		return err
+	}
+
+	schedulerCfg, err := buildCFG.BuildSchedulerConfig(a.cfg, log)
+	if err != nil {
+		return err
+	}
+	sched, closeScheduler, err := a.scheduler(schedulerCfg)
+	if err != nil {
+		return err
+	}
+	defer closeScheduler()
+
+	keyStore, err := buildCFG.BuildAuthConfig(a.cfg, log)
+	if err != nil {
+		return err
+	}
+
+	paymentCfg, err := buildCFG.BuildPaymentConfig(a.cfg, log)
+	if err != nil {
+		return err
+	}
+	payments, err := a.paymentProvider(paymentCfg)
+	if err != nil {
+		return err
+	}
+
+	routers := &api.Routers{Auth: keyStore, Health: a.healthChecker(sched).Handler()}
+	if paymentCfg.Provider == "fake" && paymentCfg.FakeServe {
+		webhookURL := paymentCfg.FakeWebhookURL
+		if webhookURL == "" {
+			webhookURL = "http://localhost:" + serverCfg.Port + "/v1/payments/webhook"
+		}
+		gateway := fake.NewServer(serverCfg.PublicURL+fake.MountPath, webhookURL, paymentCfg.WebhookSecret, log)
+		routers.FakeGateway = gateway.Handler()
+		log.Info().Msgf("Fake payment gateway mounted at %s", fake.MountPath)
+	}
+
+	unsubscribe := notify.NewUnsubscribe(serverCfg.PublicURL, notifyCfg.UnsubscribeSecret)
+
+	var deadLetters *rabbit.Client
+	if rs, ok := sched.(*scheduler.Rabbit); ok {
+		deadLetters = rs.Client()
+	}
+
+	routers.Service = service.NewService(repository, log, payments, sched, unsubscribe, deadLetters, serverCfg.PublicURL)
+	router := api.NewRouters(routers)
+
+	server := &http.Server{
+		Addr:         ":" + serverCfg.Port,
+		Handler:      router,
+		WriteTimeout: serverCfg.WriteTimeout,
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	serverErrChan := make(chan error, 1)
+	go func() {
+		log.Info().Msgf("Starting server on %s", serverCfg.Port)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErrChan <- fmt.Errorf("failed to start server: %w", err)
+		}
+	}()
+
+	select {
+	case <-ctx.Done():
+		log.Info().Msg("Received shutdown signal. Initiating shutdown...")
+	case err := <-serverErrChan:
+		return err
+	}
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer shutdownCancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Error().Msgf("Error shutting down server: %v", err)
+	}
+
+	log.Info().Msg("Shutdown complete")
+	return nil
+}
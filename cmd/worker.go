@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"net/http"
+	"time"
+
+	"fifthOne/cmd/buildCFG"
+	rabbitReader "fifthOne/internal/consumerWorker"
+	"fifthOne/internal/notify"
+	"fifthOne/internal/outbox"
+	"fifthOne/internal/refund"
+	"fifthOne/internal/reminder"
+
+	"github.com/rs/zerolog"
+)
+
+const defaultWorkerHealthAddr = ":8081"
+
+func runWorker(log *zerolog.Logger, args []string) error {
+	fs := flag.NewFlagSet("worker", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	a, err := newApp(log)
+	if err != nil {
+		return err
+	}
+	defer a.close()
+
+	serverCfg := buildCFG.BuildServerConfig(a.cfg, log)
+
+	notifyCfg, err := buildCFG.BuildNotifyConfig(a.cfg, log)
+	if err != nil {
+		return err
+	}
+
+	repository, err := a.repository(serverCfg.PublicURL, notifyCfg.LinkSecret)
+	if err != nil {
+		return err
+	}
+
+	schedulerCfg, err := buildCFG.BuildSchedulerConfig(a.cfg, log)
+	if err != nil {
+		return err
+	}
+	sched, closeScheduler, err := a.scheduler(schedulerCfg)
+	if err != nil {
+		return err
+	}
+	defer closeScheduler()
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	paymentCfg, err := buildCFG.BuildPaymentConfig(a.cfg, log)
+	if err != nil {
+		return err
+	}
+	payments, err := a.paymentProvider(paymentCfg)
+	if err != nil {
+		return err
+	}
+
+	reminderCfg, err := buildCFG.BuildReminderConfig(a.cfg, log)
+	if err != nil {
+		return err
+	}
+	sender, closeSender, err := a.notifier(notifyCfg)
+	if err != nil {
+		return err
+	}
+	defer closeSender()
+
+	reader := rabbitReader.NewReader(sched, repository)
+	reader.Start(ctx)
+
+	sweeper := rabbitReader.NewSweeper(repository)
+	sweeper.Start(ctx)
+
+	refunds := refund.NewProcessor(repository, payments, log)
+	refunds.Start(ctx)
+
+	relay := outbox.NewRelay(repository, sched, log)
+	relay.Start(ctx)
+
+	unsubscribe := notify.NewUnsubscribe(serverCfg.PublicURL, notifyCfg.UnsubscribeSecret)
+	dispatcher := notify.NewDispatcher(repository, sender, unsubscribe, notifyCfg.LinkSecret, notifyCfg.MaxAttempts, log)
+	dispatcher.Start(ctx)
+
+	reminders := reminder.NewScheduler(repository, reminderCfg.Offsets, reminderCfg.Interval, log)
+	reminders.Start(ctx)
+
+	healthAddr := a.cfg.GetString("worker.health_addr")
+	if healthAddr == "" {
+		healthAddr = defaultWorkerHealthAddr
+	}
+	healthServer := &http.Server{Addr: healthAddr, Handler: a.healthChecker(sched).Handler()}
+	go func() {
+		log.Info().Msgf("Worker health probes on %s", healthAddr)
+		if err := healthServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error().Err(err).Msg("Health server failed")
+		}
+	}()
+
+	<-ctx.Done()
+	log.Info().Msg("Received shutdown signal. Stopping worker...")
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer shutdownCancel()
+	_ = healthServer.Shutdown(shutdownCtx)
+	reader.Stop()
+	sweeper.Stop()
+	refunds.Stop()
+	relay.Stop()
+	reminders.Stop()
+	dispatcher.Stop()
+
+	log.Info().Msg("Shutdown complete")
+	return nil
+}
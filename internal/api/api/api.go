@@ -2,27 +2,72 @@ package api
 
 import (
 	"fifthOne/cmd/middleware"
+	"fifthOne/internal/auth"
+	"fifthOne/internal/payment/fake"
 	"fifthOne/internal/service"
 	"github.com/gin-contrib/cors"
 	"github.com/wb-go/wbf/ginext"
+	"net/http"
 )
 
 type Routers struct {
 	Service service.Service
+	Auth    *auth.KeyStore
+	// FakeGateway, when set, is served under fake.MountPath.
+	FakeGateway http.Handler
+	// Health serves /healthz and /readyz.
+	Health http.Handler
 }
 
 func NewRouters(r *Routers) *ginext.Engine {
 	app := ginext.New("release")
 
 	app.Use(middleware.LoggingMiddleware())
-	app.Use(cors.Default())
+	corsCfg := cors.DefaultConfig()
+	corsCfg.AllowAllOrigins = true
+	corsCfg.AddAllowHeaders("Authorization", "X-API-Key")
+	app.Use(cors.New(corsCfg))
 	apiGroup := app.Group("/v1")
+	apiGroup.Use(middleware.Authenticate(r.Auth))
 
-	apiGroup.POST("/events", r.Service.CreateEvent)
+	manage := middleware.RequireRole(auth.RoleAdmin, auth.RoleOrganizer)
+
+	apiGroup.POST("/events", manage, r.Service.CreateEvent)
+	apiGroup.PATCH("/events/:id", manage, r.Service.UpdateEvent)
 	apiGroup.POST("/events/:id/book", r.Service.Book)
 	apiGroup.POST("/events/:id/confirm", r.Service.Confirm)
+	apiGroup.POST("/events/:id/registrations/:regId/cancel", r.Service.CancelRegistration)
+	apiGroup.GET("/events/:id/checkout", r.Service.Checkout)
 	apiGroup.GET("/events/:id", r.Service.GetInfo)
 	apiGroup.GET("/events", r.Service.GetAllEvents)
+	apiGroup.GET("/registrations/:id/history", manage, r.Service.GetRegistrationHistory)
+	apiGroup.POST("/registrations/:id/cancel", manage, r.Service.CancelRegistrationByAdmin)
+	apiGroup.POST("/registrations/:id/extend", manage, r.Service.ExtendRegistration)
+	apiGroup.POST("/payments/webhook", r.Service.PaymentWebhook)
+	apiGroup.GET("/unsubscribe", r.Service.UnsubscribePage)
+	apiGroup.POST("/unsubscribe", r.Service.Unsubscribe)
+
+	admin := apiGroup.Group("/admin", middleware.RequireRole(auth.RoleAdmin))
+	admin.GET("/dead-letters", r.Service.ListDeadLetters)
+	admin.GET("/dead-letters/:id", r.Service.GetDeadLetter)
+	admin.POST("/dead-letters/:id/replay", r.Service.ReplayDeadLetter)
+	admin.DELETE("/dead-letters/:id", r.Service.DeleteDeadLetter)
+	admin.DELETE("/dead-letters", r.Service.PurgeDeadLetters)
+
+	if r.FakeGateway != nil {
+		gateway := http.StripPrefix(fake.MountPath, r.FakeGateway)
+		app.Any(fake.MountPath+"/*path", func(c *ginext.Context) {
+			gateway.ServeHTTP(c.Writer, c.Request)
+		})
+	}
+
+	if r.Health != nil {
+		probe := func(c *ginext.Context) {
+			r.Health.ServeHTTP(c.Writer, c.Request)
+		}
+		app.GET("/healthz", probe)
+		app.GET("/readyz", probe)
+	}
 
 	app.GET("/", func(c *ginext.Context) {
 		c.File("./frontend/index.html")
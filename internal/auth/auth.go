@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/wb-go/wbf/ginext"
+)
+
+type Role string
+
+const (
+	RoleAdmin     Role = "admin"
+	RoleOrganizer Role = "organizer"
+	RoleAttendee  Role = "attendee"
+)
+
+func (r Role) Valid() bool {
+	return r == RoleAdmin || r == RoleOrganizer || r == RoleAttendee
+}
+
+// Principal is the caller of a request. Requests without credentials get an anonymous attendee.
+type Principal struct {
+	Subject string
+	Role    Role
+}
+
+var Anonymous = Principal{Role: RoleAttendee}
+
+func (p Principal) Authenticated() bool {
+	return p.Subject != ""
+}
+
+func (p Principal) IsAdmin() bool {
+	return p.Role == RoleAdmin
+}
+
+// CanManage reports whether the principal may see and change data of an event owned by organizerID.
+func (p Principal) CanManage(organizerID string) bool {
+	if p.IsAdmin() {
+		return true
+	}
+	return p.Role == RoleOrganizer && p.Subject != "" && p.Subject == organizerID
+}
+
+const principalKey = "auth.principal"
+
+func SetPrincipal(c *ginext.Context, p Principal) {
+	c.Set(principalKey, p)
+}
+
+func FromContext(c *ginext.Context) Principal {
+	if v, ok := c.Get(principalKey); ok {
+		if p, ok := v.(Principal); ok {
+			return p
+		}
+	}
+	return Anonymous
+}
+
+type APIKey struct {
+	Key     string `mapstructure:"key"`
+	Role    Role   `mapstructure:"role"`
+	Subject string `mapstructure:"subject"`
+}
+
+// KeyStore resolves API keys to principals. Keys are indexed by their SHA-256 digest
+// so the raw secrets are not kept around after startup.
+type KeyStore struct {
+	keys map[[sha256.Size]byte]Principal
+}
+
+func NewKeyStore(keys []APIKey) (*KeyStore, error) {
+	store := &KeyStore{keys: make(map[[sha256.Size]byte]Principal, len(keys))}
+	for i, k := range keys {
+		if k.Key == "" {
+			return nil, fmt.Errorf("api key #%d: key is empty", i)
+		}
+		if !k.Role.Valid() {
+			return nil, fmt.Errorf("api key #%d: unknown role %q", i, k.Role)
+		}
+		if k.Subject == "" {
+			return nil, fmt.Errorf("api key #%d: subject is empty", i)
+		}
+		digest := sha256.Sum256([]byte(k.Key))
+		if _, dup := store.keys[digest]; dup {
+			return nil, fmt.Errorf("api key #%d: duplicate key", i)
+		}
+		store.keys[digest] = Principal{Subject: k.Subject, Role: k.Role}
+	}
+	return store, nil
+}
+
+func (s *KeyStore) Lookup(key string) (Principal, bool) {
+	p, ok := s.keys[sha256.Sum256([]byte(key))]
+	return p, ok
+}
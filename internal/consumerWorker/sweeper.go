@@ -0,0 +1,90 @@
+package consumerWorker
+
+import (
+	"context"
+	"time"
+
+	"fifthOne/internal/repo"
+
+	"github.com/wb-go/wbf/zlog"
+)
+
+const (
+	defaultSweepInterval = 30 * time.Second
+	defaultSweepBatch    = 100
+)
+
+// Sweeper periodically expires pending registrations past their expires_at. It is the safety net
+// for expiry messages that never arrive through RabbitMQ, and may run on several replicas at once.
+type Sweeper struct {
+	repo     repo.Repository
+	interval time.Duration
+	batch    int
+	done     chan struct{}
+	cancel   context.CancelFunc
+}
+
+func NewSweeper(repo repo.Repository) *Sweeper {
+	return &Sweeper{
+		repo:     repo,
+		interval: defaultSweepInterval,
+		batch:    defaultSweepBatch,
+		done:     make(chan struct{}),
+	}
+}
+
+func (s *Sweeper) Start(ctx context.Context) {
+	cctx, cancel := context.WithCancel(ctx)
+	s.cancel = cancel
+
+	zlog.Logger.Info().Msg("🧹 Expiry sweeper started")
+
+	go func() {
+		defer close(s.done)
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			// a full batch means more overdue registrations are probably waiting
+			if s.sweepOnce(cctx) == s.batch && cctx.Err() == nil {
+				continue
+			}
+
+			select {
+			case <-cctx.Done():
+				zlog.Logger.Info().Msg("🛑 Expiry sweeper stopped by context")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (s *Sweeper) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+		<-s.done
+	}
+}
+
+// sweepOnce expires one batch and returns how many registrations were expired.
+func (s *Sweeper) sweepOnce(ctx context.Context) int {
+	expired, promoted, err := s.repo.ExpireOverdueTx(ctx, s.batch)
+	if err != nil {
+		if ctx.Err() == nil {
+			zlog.Logger.Error().Err(err).Msg("Failed to sweep overdue registrations")
+		}
+		return 0
+	}
+	if len(expired) == 0 {
+		return 0
+	}
+
+	zlog.Logger.Info().
+		Int("expired", len(expired)).
+		Int("promoted", len(promoted)).
+		Msg("🧹 Overdue registrations expired by sweeper, notifications queued")
+
+	return len(expired)
+}
@@ -2,28 +2,27 @@ package consumerWorker
 
 import (
 	"context"
-	"encoding/json"
-	"fifthOne/internal/dto"
-	"fifthOne/internal/mailer"
 
-	"fifthOne/internal/rabbit"
 	"fifthOne/internal/repo"
+	"fifthOne/internal/scheduler"
 
 	"github.com/wb-go/wbf/zlog"
 )
 
 type Reader struct {
-	RMQ    *rabbit.Client
+	sched  scheduler.Scheduler
 	repo   repo.Repository
 	done   chan struct{}
 	cancel context.CancelFunc
 }
 
-func NewReader(rmq *rabbit.Client, repo repo.Repository) *Reader {
+// NewReader returns a reader that expires registrations as their scheduled expiries come due. The
+// notifications about it are queued by the repository in the same transaction.
+func NewReader(sched scheduler.Scheduler, repo repo.Repository) *Reader {
 	return &Reader{
-		RMQ:  rmq,
-		repo: repo,
-		done: make(chan struct{}),
+		sched: sched,
+		repo:  repo,
+		done:  make(chan struct{}),
 	}
 }
 
@@ -31,86 +30,54 @@ func (r *Reader) Start(ctx context.Context) {
 	cctx, cancel := context.WithCancel(ctx)
 	r.cancel = cancel
 
-	zlog.Logger.Info().Msg("🐇 RabbitMQ Reader started")
+	zlog.Logger.Info().Msg("🐇 Expiry reader started")
 
 	go func() {
 		defer close(r.done)
 
-		handler := func(body []byte) error {
-			var msg dto.RegistrationOperateMessage
-			if err := json.Unmarshal(body, &msg); err != nil {
-				zlog.Logger.Error().
-					Err(err).
-					Msgf("Failed to unmarshal message: %s", string(body))
-				return err
-			}
-
+		handler := func(ctx context.Context, e scheduler.Expiry) error {
+			registrationID := e.RegistrationID
 			zlog.Logger.Info().
-				Int64("registration_id", msg.RegistrationID).
-				Int64("event_id", msg.EventID).
-				Msg("📩 Received message from RabbitMQ")
+				Int64("registration_id", registrationID).
+				Int64("generation", e.Generation).
+				Msg("📩 Registration expiry is due")
 
-			canceled, err := r.repo.CancelIfNotConfirmedTx(cctx, msg.RegistrationID)
+			canceled, promoted, err := r.repo.CancelIfNotConfirmedTx(ctx, registrationID, e.Generation)
 			if err != nil {
 				zlog.Logger.Error().
 					Err(err).
-					Int64("registration_id", msg.RegistrationID).
+					Int64("registration_id", registrationID).
 					Msg("Failed to cancel registration (DB operation)")
 				return err
 			}
 
 			if !canceled {
 				zlog.Logger.Info().
-					Int64("registration_id", msg.RegistrationID).
-					Msg("⏳ Registration already confirmed or canceled — skipping email")
-				return nil
-			}
-
-			reg, err := r.repo.GetRegistrationByID(ctx, msg.RegistrationID)
-			if err != nil {
-				zlog.Logger.Error().
-					Err(err).
-					Int64("registration_id", msg.RegistrationID).
-					Msg("Failed to get registration from DB in worker")
+					Int64("registration_id", registrationID).
+					Msg("⏳ Registration is no longer pending or its deadline was extended — skipping")
 				return nil
 			}
 
-			event, err := r.repo.GetEventByID(ctx, int64(reg.EventID))
-			if err != nil {
-				zlog.Logger.Error().
-					Err(err).
-					Int64("event_id", int64(reg.EventID)).
-					Msg("Failed to get event from DB in worker")
-				return nil
-			}
+			zlog.Logger.Info().
+				Int64("registration_id", registrationID).
+				Msg("⌛ Registration expired, notification queued")
 
-			if err := mailer.SendRegistrationEmail(
-				&zlog.Logger,
-				event.Name,
-				"canceled",
-				reg.Email,
-				0,
-			); err != nil {
-				zlog.Logger.Warn().
-					Err(err).
-					Msg("Failed to send notification on e-mail")
-			} else {
+			if promoted != nil {
 				zlog.Logger.Info().
-					Str("email", reg.Email).
-					Int64("registration_id", msg.RegistrationID).
-					Msg("📧 Cancellation email sent successfully")
+					Int("registration_id", promoted.ID).
+					Int("event_id", promoted.EventID).
+					Msg("🎟 Waitlisted registration promoted to pending")
 			}
 
 			return nil
 		}
 
-		if err := r.RMQ.Consume(handler); err != nil {
-			zlog.Logger.Error().Err(err).Msg("Failed to start consuming")
+		if err := r.sched.Run(cctx, handler); err != nil {
+			zlog.Logger.Error().Err(err).Msg("Failed to run expiry scheduler")
 			return
 		}
 
-		<-cctx.Done()
-		zlog.Logger.Info().Msg("🛑 RabbitMQ Reader stopped by context")
+		zlog.Logger.Info().Msg("🛑 Expiry reader stopped by context")
 	}()
 }
 
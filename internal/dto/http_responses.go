@@ -1,6 +1,7 @@
 package dto
 
 import (
+	"fifthOne/internal/model"
 	"github.com/wb-go/wbf/ginext"
 	"time"
 )
@@ -14,59 +15,157 @@ const (
 	EventNotFound         = "EVENT_NOT_FOUND"
 	RegistrationNotFound  = "REGISTRATION_NOT_FOUND"
 	RegistrationDuplicate = "REGISTRATION_DUPLICATE"
+	RegistrationForbidden = "REGISTRATION_FORBIDDEN"
+	CancellationRejected  = "CANCELLATION_REJECTED"
+
+	ConfirmationTokenInvalid = "CONFIRMATION_TOKEN_INVALID"
+	PaymentRequired          = "PAYMENT_REQUIRED"
+	PaymentNotRequired       = "PAYMENT_NOT_REQUIRED"
+
+	InvalidStatusTransition = "INVALID_STATUS_TRANSITION"
+	Forbidden               = "FORBIDDEN"
+	Unauthorized            = "UNAUTHORIZED"
+
+	DeadLetterNotFound = "DEAD_LETTER_NOT_FOUND"
+
+	RegistrationNotPending = "REGISTRATION_NOT_PENDING"
+
+	UnsubscribeLinkInvalid = "UNSUBSCRIBE_LINK_INVALID"
 )
 
 type CreateRegistrationRequest struct {
 	FullName string `json:"full_name" validate:"required,min=3,max=255"`
 	Email    string `json:"email" validate:"required,email"`
-	Phone    string `json:"phone" validate:"required"`
+	Phone    string `json:"phone" validate:"required,phone"`
+	// NotifyChannel is where notifications go: email (the default), sms or both.
+	NotifyChannel string `json:"notify_channel" validate:"omitempty,oneof=email sms both"`
 }
 type RegistrationResponse struct {
+	ID               int64     `json:"id"`
+	EventID          int64     `json:"event_id"`
+	FullName         string    `json:"full_name"`
+	Email            string    `json:"email"`
+	Phone            string    `json:"phone,omitempty"`
+	NotifyChannel    string    `json:"notify_channel,omitempty"`
+	CreatedAt        time.Time `json:"created_at"`
+	Status           string    `json:"status"`
+	UpdatedAt        time.Time `json:"updated_at"`
+	WaitlistPosition int       `json:"waitlist_position,omitempty"`
+	// ExpiresAt is the payment deadline of a pending registration.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// ConfirmationToken is returned only once, when the registration becomes pending.
+	ConfirmationToken string `json:"confirmation_token,omitempty"`
+	ConfirmURL        string `json:"confirm_url,omitempty"`
+	// PaymentURL leads to the checkout page for registrations of paid events.
+	PaymentURL string          `json:"payment_url,omitempty"`
+	Refund     *RefundResponse `json:"refund,omitempty"`
+}
+type RefundResponse struct {
 	ID        int64     `json:"id"`
-	EventID   int64     `json:"event_id"`
-	FullName  string    `json:"full_name"`
-	Email     string    `json:"email"`
-	CreatedAt time.Time `json:"created_at"`
+	Amount    int64     `json:"amount"`
+	Currency  string    `json:"currency"`
 	Status    string    `json:"status"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
+type RegistrationHistoryResponse struct {
+	ID        int64     `json:"id"`
+	OldStatus string    `json:"old_status,omitempty"`
+	NewStatus string    `json:"new_status"`
+	Actor     string    `json:"actor"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
 type RegistrationOperateMessage struct {
 	RegistrationID int64     `json:"registration_id"`
 	EventID        int64     `json:"event_id"`
 	ExpireAt       time.Time `json:"expire_at"`
+	Generation     int64     `json:"generation"`
 }
 
 type CreateEventRequest struct {
-	Name                  string    `json:"name" validate:"required"`
-	Description           string    `json:"description"`
-	StartTime             time.Time `json:"start_time" validate:"required"`
-	EndTime               time.Time `json:"end_time"`
-	Location              string    `json:"location"`
-	Capacity              int       `json:"capacity" validate:"gt=0"`
-	PaymentTimeoutMinutes int       `json:"payment_timeout_minutes" validate:"gte=1"`
+	Name                      string    `json:"name" validate:"required"`
+	Description               string    `json:"description"`
+	StartTime                 time.Time `json:"start_time" validate:"required"`
+	EndTime                   time.Time `json:"end_time"`
+	Location                  string    `json:"location"`
+	Capacity                  int       `json:"capacity" validate:"gt=0"`
+	PaymentTimeoutMinutes     int       `json:"payment_timeout_minutes" validate:"gte=1"`
+	CancellationDeadlineHours int       `json:"cancellation_deadline_hours" validate:"gte=0"`
+	Price                     int64     `json:"price" validate:"gte=0"`
+	Currency                  string    `json:"currency" validate:"omitempty,len=3,alpha"`
+	RefundPolicy              string    `json:"refund_policy" validate:"omitempty,oneof=none full tiered"`
+	RefundFullHours           int       `json:"refund_full_hours" validate:"gte=0"`
+	RefundPartialPercent      int       `json:"refund_partial_percent" validate:"gte=0,lte=100"`
+}
+
+// ListEventsRequest is the query of GET /v1/events.
+type ListEventsRequest struct {
+	Limit  int    `form:"limit" validate:"omitempty,gte=1,lte=100"`
+	Cursor string `form:"cursor"`
+	// From and To bound start_time, RFC 3339.
+	From     *time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To       *time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Location string     `form:"location" validate:"max=255"`
+	HasSeats bool       `form:"has_seats"`
+	When     string     `form:"when" validate:"omitempty,oneof=upcoming past"`
+	Sort     string     `form:"sort" validate:"omitempty,oneof=start_time -start_time created_at -created_at name -name"`
+}
+
+// UpdateEventRequest changes the given fields of an event; omitted fields are kept.
+type UpdateEventRequest struct {
+	Name        *string    `json:"name" validate:"omitempty,min=1"`
+	Description *string    `json:"description"`
+	StartTime   *time.Time `json:"start_time"`
+	EndTime     *time.Time `json:"end_time"`
+	Location    *string    `json:"location"`
 }
 type ConfirmRegistrationRequest struct {
-	EventID int64  `json:"event_id"`
-	RegID   int64  `json:"registration_id"`
-	Email   string `json:"email"`
-	Status  string `json:"status,omitempty"`
+	Token string `json:"token" validate:"required"`
+}
+type CancelRegistrationRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+type AdminCancelRegistrationRequest struct {
+	Reason string `json:"reason" validate:"max=500"`
+}
+type ExtendRegistrationRequest struct {
+	Minutes int `json:"minutes" validate:"gte=1,lte=1440"`
 }
 type EventResponse struct {
-	ID                    int64     `json:"id"`
-	Name                  string    `json:"name"`
-	Description           string    `json:"description"`
-	StartTime             time.Time `json:"start_time"`
-	EndTime               time.Time `json:"end_time"`
-	Location              string    `json:"location"`
-	Capacity              int       `json:"capacity"`
-	PaymentTimeoutMinutes int       `json:"payment_timeout_minutes"`
-	CreatedAt             time.Time `json:"created_at"`
+	ID                        int64     `json:"id"`
+	Name                      string    `json:"name"`
+	Description               string    `json:"description"`
+	StartTime                 time.Time `json:"start_time"`
+	EndTime                   time.Time `json:"end_time"`
+	Location                  string    `json:"location"`
+	Capacity                  int       `json:"capacity"`
+	PaymentTimeoutMinutes     int       `json:"payment_timeout_minutes"`
+	CancellationDeadlineHours int       `json:"cancellation_deadline_hours"`
+	OrganizerID               string    `json:"organizer_id,omitempty"`
+	Price                     int64     `json:"price"`
+	Currency                  string    `json:"currency"`
+	RefundPolicy              string    `json:"refund_policy"`
+	RefundFullHours           int       `json:"refund_full_hours"`
+	RefundPartialPercent      int       `json:"refund_partial_percent"`
+	CreatedAt                 time.Time `json:"created_at"`
 }
 
 type Response struct {
 	Status string `json:"status"`
 	Error  *Error `json:"error,omitempty"`
 	Data   any    `json:"data,omitempty"`
+	Meta   *Meta  `json:"meta,omitempty"`
+}
+
+// Meta describes a page of a list.
+type Meta struct {
+	// Total is the number of items on all pages.
+	Total int `json:"total"`
+	Limit int `json:"limit"`
+	// NextCursor is passed as ?cursor= to get the next page; empty on the last one.
+	NextCursor string `json:"next_cursor,omitempty"`
 }
 
 type Error struct {
@@ -74,18 +173,26 @@ type Error struct {
 	Desc string `json:"desc"`
 }
 type EventInfoResponse struct {
-	ID                    int64                  `json:"id"`
-	Name                  string                 `json:"name"`
-	Description           string                 `json:"description"`
-	StartTime             time.Time              `json:"start_time"`
-	EndTime               time.Time              `json:"end_time"`
-	Location              string                 `json:"location"`
-	Capacity              int                    `json:"capacity"`
-	AvailableSeats        int                    `json:"available_seats"`
-	PaymentTimeoutMinutes int                    `json:"payment_timeout_minutes"`
-	CreatedAt             time.Time              `json:"created_at"`
-	UpdatedAt             time.Time              `json:"updated_at"`
-	Registrations         []RegistrationResponse `json:"registrations,omitempty"`
+	ID                        int64                  `json:"id"`
+	Name                      string                 `json:"name"`
+	Description               string                 `json:"description"`
+	StartTime                 time.Time              `json:"start_time"`
+	EndTime                   time.Time              `json:"end_time"`
+	Location                  string                 `json:"location"`
+	Capacity                  int                    `json:"capacity"`
+	AvailableSeats            int                    `json:"available_seats"`
+	WaitlistLength            int                    `json:"waitlist_length"`
+	PaymentTimeoutMinutes     int                    `json:"payment_timeout_minutes"`
+	CancellationDeadlineHours int                    `json:"cancellation_deadline_hours"`
+	OrganizerID               string                 `json:"organizer_id,omitempty"`
+	Price                     int64                  `json:"price"`
+	Currency                  string                 `json:"currency"`
+	RefundPolicy              string                 `json:"refund_policy"`
+	RefundFullHours           int                    `json:"refund_full_hours"`
+	RefundPartialPercent      int                    `json:"refund_partial_percent"`
+	CreatedAt                 time.Time              `json:"created_at"`
+	UpdatedAt                 time.Time              `json:"updated_at"`
+	Registrations             []RegistrationResponse `json:"registrations,omitempty"`
 }
 
 func BadResponseError(c *ginext.Context, code, desc string) {
@@ -128,6 +235,105 @@ func RegistrationDuplicateError(c *ginext.Context) {
 	BadResponseError(c, RegistrationDuplicate, "You have already registered for this event")
 }
 
+func RefundFromModel(rf *model.Refund) *RefundResponse {
+	if rf == nil {
+		return nil
+	}
+	return &RefundResponse{
+		ID:        rf.ID,
+		Amount:    rf.Amount,
+		Currency:  rf.Currency,
+		Status:    string(rf.Status),
+		Reason:    rf.Reason,
+		CreatedAt: rf.CreatedAt,
+		UpdatedAt: rf.UpdatedAt,
+	}
+}
+
+func RegistrationForbiddenError(c *ginext.Context) {
+	BadResponseError(c, RegistrationForbidden, "Registration belongs to another attendee")
+}
+
+func CancellationRejectedError(c *ginext.Context, desc string) {
+	BadResponseError(c, CancellationRejected, desc)
+}
+
+func UnauthorizedError(c *ginext.Context) {
+	c.JSON(401, Response{
+		Status: "error",
+		Error: &Error{
+			Code: Unauthorized,
+			Desc: "Valid API key required",
+		},
+	})
+}
+
+func ForbiddenError(c *ginext.Context) {
+	c.JSON(403, Response{
+		Status: "error",
+		Error: &Error{
+			Code: Forbidden,
+			Desc: "Access denied",
+		},
+	})
+}
+
+func ConflictError(c *ginext.Context, code, desc string) {
+	c.JSON(409, Response{
+		Status: "error",
+		Error: &Error{
+			Code: code,
+			Desc: desc,
+		},
+	})
+}
+
+func InvalidStatusTransitionError(c *ginext.Context, from, to string) {
+	ConflictError(c, InvalidStatusTransition, "Registration cannot move from '"+from+"' to '"+to+"'")
+}
+
+func PaymentRequiredError(c *ginext.Context, desc string) {
+	c.JSON(402, Response{
+		Status: "error",
+		Error: &Error{
+			Code: PaymentRequired,
+			Desc: desc,
+		},
+	})
+}
+
+func BadGatewayError(c *ginext.Context) {
+	c.JSON(502, Response{
+		Status: "error",
+		Error: &Error{
+			Code: ServiceUnavailable,
+			Desc: "Payment provider is unavailable. Please try again later.",
+		},
+	})
+}
+
+func DeadLetterNotFoundError(c *ginext.Context) {
+	BadResponseError(c, DeadLetterNotFound, "Dead letter not found")
+}
+
+func RegistrationNotPendingError(c *ginext.Context) {
+	ConflictError(c, RegistrationNotPending, "Only a pending registration can be extended")
+}
+
+func UnsubscribeLinkInvalidError(c *ginext.Context) {
+	BadResponseError(c, UnsubscribeLinkInvalid, "Unsubscribe link is invalid")
+}
+
+func ServiceUnavailableError(c *ginext.Context, desc string) {
+	c.JSON(503, Response{
+		Status: "error",
+		Error: &Error{
+			Code: ServiceUnavailable,
+			Desc: desc,
+		},
+	})
+}
+
 func SuccessResponse(c *ginext.Context, data any) {
 	c.JSON(200, Response{
 		Status: "ok",
@@ -135,6 +341,14 @@ func SuccessResponse(c *ginext.Context, data any) {
 	})
 }
 
+func SuccessPageResponse(c *ginext.Context, data any, meta Meta) {
+	c.JSON(200, Response{
+		Status: "ok",
+		Data:   data,
+		Meta:   &meta,
+	})
+}
+
 func SuccessCreatedResponse(c *ginext.Context, data any) {
 	c.JSON(201, Response{
 		Status: "ok",
@@ -0,0 +1,74 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+const checkTimeout = 2 * time.Second
+
+// Check reports whether a dependency is usable right now.
+type Check func(ctx context.Context) error
+
+// Checker serves liveness and readiness probes. Checks are registered at startup, before the
+// handler starts serving.
+type Checker struct {
+	names  []string
+	checks map[string]Check
+}
+
+func New() *Checker {
+	return &Checker{checks: make(map[string]Check)}
+}
+
+func (c *Checker) Add(name string, check Check) {
+	if _, ok := c.checks[name]; !ok {
+		c.names = append(c.names, name)
+	}
+	c.checks[name] = check
+}
+
+// Ready runs every check and returns the result per dependency.
+func (c *Checker) Ready(ctx context.Context) (map[string]string, bool) {
+	results := make(map[string]string, len(c.names))
+	ready := true
+	for _, name := range c.names {
+		cctx, cancel := context.WithTimeout(ctx, checkTimeout)
+		err := c.checks[name](cctx)
+		cancel()
+
+		if err != nil {
+			results[name] = err.Error()
+			ready = false
+			continue
+		}
+		results[name] = "ok"
+	}
+	return results, ready
+}
+
+// Handler serves GET /healthz, which only says the process is up, and GET /readyz, which
+// answers 503 while any check fails.
+func (c *Checker) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"status": "ok"})
+	})
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+		results, ready := c.Ready(r.Context())
+		status, code := "ok", http.StatusOK
+		if !ready {
+			status, code = "unavailable", http.StatusServiceUnavailable
+		}
+		writeJSON(w, code, map[string]any{"status": status, "checks": results})
+	})
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, code int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(body)
+}
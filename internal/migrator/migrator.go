@@ -0,0 +1,404 @@
+package migrator
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// lockKey is the pg_advisory_lock key shared by every replica running migrations.
+const lockKey int64 = 7_204_113_500
+
+// legacyBaseline is the migration the old runner left applied without recording it.
+const legacyBaseline int64 = 1
+
+var (
+	ErrNoMigrations     = errors.New("no migrations found")
+	ErrUnknownVersion   = errors.New("unknown migration version")
+	ErrMissingDownFile  = errors.New("migration has no down file")
+	ErrInvalidStepCount = errors.New("step count must not be negative")
+	ErrDuplicateVersion = errors.New("duplicate migration version")
+)
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+}
+
+type Migrator struct {
+	db         *sql.DB
+	log        *zerolog.Logger
+	migrations []Migration
+}
+
+func New(db *sql.DB, dir string, log *zerolog.Logger) (*Migrator, error) {
+	if db == nil {
+		return nil, fmt.Errorf("db cannot be nil")
+	}
+	migrations, err := Load(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, log: log, migrations: migrations}, nil
+}
+
+// Load reads NNNN_name.up.sql / NNNN_name.down.sql pairs from dir, ordered by version. Two up or two
+// down files with the same version, or an up and a down file of one version with different names,
+// are rejected rather than merged.
+func Load(dir string) ([]Migration, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations dir %s: %w", dir, err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+
+		fileName := entry.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(fileName, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(fileName, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+
+		base := strings.TrimSuffix(fileName, "."+direction+".sql")
+		versionStr, name, _ := strings.Cut(base, "_")
+		version, err := strconv.ParseInt(versionStr, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %w", fileName, err)
+		}
+
+		sqlBytes, err := os.ReadFile(filepath.Join(dir, fileName))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration file %s: %w", fileName, err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		if m.Name != name {
+			return nil, fmt.Errorf("%w: %d is used by %s and %s", ErrDuplicateVersion, version, m.Name, name)
+		}
+		target := &m.Up
+		if direction == "down" {
+			target = &m.Down
+		}
+		if *target != "" {
+			return nil, fmt.Errorf("%w: %d has two %s files", ErrDuplicateVersion, version, direction)
+		}
+		*target = string(sqlBytes)
+	}
+
+	if len(byVersion) == 0 {
+		return nil, ErrNoMigrations
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d (%s) has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// Up applies up to n pending migrations in version order; n == 0 applies all of them.
+func (m *Migrator) Up(ctx context.Context, n int) error {
+	if n < 0 {
+		return ErrInvalidStepCount
+	}
+
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		if err := m.adoptLegacySchema(ctx, conn); err != nil {
+			return err
+		}
+		applied, err := m.appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		count := 0
+		for _, mig := range m.migrations {
+			if _, ok := applied[mig.Version]; ok {
+				continue
+			}
+			if n > 0 && count >= n {
+				break
+			}
+			if err := m.apply(ctx, conn, mig.Version, mig.Up, func(tx *sql.Tx) error {
+				_, err := tx.ExecContext(ctx,
+					`INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, NOW())`,
+					mig.Version, mig.Name)
+				return err
+			}); err != nil {
+				return err
+			}
+			m.log.Info().Int64("version", mig.Version).Str("name", mig.Name).Msg("migration applied")
+			count++
+		}
+
+		if count == 0 {
+			m.log.Info().Msg("schema is up to date")
+		}
+		return nil
+	})
+}
+
+// Down rolls back the n most recently applied migrations; n == 0 rolls back all of them.
+func (m *Migrator) Down(ctx context.Context, n int) error {
+	if n < 0 {
+		return ErrInvalidStepCount
+	}
+
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		count := 0
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.Version]; !ok {
+				continue
+			}
+			if n > 0 && count >= n {
+				break
+			}
+			if mig.Down == "" {
+				return fmt.Errorf("%w: %d (%s)", ErrMissingDownFile, mig.Version, mig.Name)
+			}
+			if err := m.apply(ctx, conn, mig.Version, mig.Down, func(tx *sql.Tx) error {
+				_, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = $1`, mig.Version)
+				return err
+			}); err != nil {
+				return err
+			}
+			m.log.Info().Int64("version", mig.Version).Str("name", mig.Name).Msg("migration rolled back")
+			count++
+		}
+		return nil
+	})
+}
+
+// Status reports every known migration together with whether it has been applied. It only reads:
+// it neither takes the migration lock nor creates schema_migrations, which is treated as empty
+// while it does not exist.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied := make(map[int64]time.Time)
+	exists, err := tableExists(ctx, m.db, "schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		if applied, err = m.appliedVersions(ctx, m.db); err != nil {
+			return nil, err
+		}
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		st := Status{Version: mig.Version, Name: mig.Name}
+		if at, ok := applied[mig.Version]; ok {
+			appliedAt := at
+			st.Applied = true
+			st.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, st)
+	}
+	return statuses, nil
+}
+
+// Force marks every migration up to and including version as applied and every later one
+// as not applied, without executing any SQL. It is meant for recovering from a failed manual fix.
+func (m *Migrator) Force(ctx context.Context, version int64) error {
+	known := version == 0
+	for _, mig := range m.migrations {
+		if mig.Version == version {
+			known = true
+			break
+		}
+	}
+	if !known {
+		return fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("failed to start transaction: %w", err)
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version > $1`, version); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("failed to force version: %w", err)
+		}
+		for _, mig := range m.migrations {
+			if mig.Version > version {
+				break
+			}
+			if _, err := tx.ExecContext(ctx, `
+				INSERT INTO schema_migrations (version, name, applied_at)
+				VALUES ($1, $2, NOW())
+				ON CONFLICT (version) DO NOTHING
+			`, mig.Version, mig.Name); err != nil {
+				_ = tx.Rollback()
+				return fmt.Errorf("failed to force version: %w", err)
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit transaction: %w", err)
+		}
+		m.log.Warn().Int64("version", version).Msg("schema version forced")
+		return nil
+	})
+}
+
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockKey); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer func() {
+		if _, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockKey); err != nil {
+			m.log.Error().Err(err).Msg("failed to release migration lock")
+		}
+	}()
+
+	if _, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			applied_at TIMESTAMP NOT NULL DEFAULT NOW()
+		)
+	`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	return fn(conn)
+}
+
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func tableExists(ctx context.Context, q querier, table string) (bool, error) {
+	var exists bool
+	if err := q.QueryRowContext(ctx, `SELECT to_regclass($1) IS NOT NULL`, table).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to look up table %s: %w", table, err)
+	}
+	return exists, nil
+}
+
+// adoptLegacySchema records the first migration as applied on a database set up by the old
+// runner, which executed every up file without keeping track: its tables exist, but
+// schema_migrations is empty. The caller must hold the migration lock.
+func (m *Migrator) adoptLegacySchema(ctx context.Context, conn *sql.Conn) error {
+	if len(m.migrations) == 0 || m.migrations[0].Version != legacyBaseline {
+		return nil
+	}
+	var recorded bool
+	if err := conn.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM schema_migrations)`).Scan(&recorded); err != nil {
+		return fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	if recorded {
+		return nil
+	}
+	legacy, err := tableExists(ctx, conn, "events")
+	if err != nil || !legacy {
+		return err
+	}
+
+	first := m.migrations[0]
+	if _, err := conn.ExecContext(ctx,
+		`INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, NOW())`,
+		first.Version, first.Name); err != nil {
+		return fmt.Errorf("failed to record legacy schema: %w", err)
+	}
+	m.log.Warn().Int64("version", first.Version).Msg("existing schema without schema_migrations, first migration marked as applied")
+	return nil
+}
+
+func (m *Migrator) appliedVersions(ctx context.Context, q querier) (map[int64]time.Time, error) {
+	rows, err := q.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan schema_migrations: %w", err)
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, version int64, query string, record func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("failed to apply migration %d: %w", version, err)
+	}
+	if err := record(tx); err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("failed to record migration %d: %w", version, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %d: %w", version, err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package migrator
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeMigrations(t *testing.T, files ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("SELECT 1;"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoad(t *testing.T) {
+	dir := writeMigrations(t,
+		"000002_b.up.sql", "000002_b.down.sql",
+		"000001_a.up.sql", "000001_a.down.sql",
+		"000010_c.up.sql",
+		"README.md",
+	)
+
+	migrations, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		version int64
+		name    string
+		down    bool
+	}{{1, "a", true}, {2, "b", true}, {10, "c", false}}
+	if len(migrations) != len(want) {
+		t.Fatalf("got %d migrations, want %d", len(migrations), len(want))
+	}
+	for i, w := range want {
+		m := migrations[i]
+		if m.Version != w.version || m.Name != w.name || (m.Down != "") != w.down {
+			t.Errorf("migration %d = {%d %s down=%t}, want {%d %s down=%t}",
+				i, m.Version, m.Name, m.Down != "", w.version, w.name, w.down)
+		}
+	}
+}
+
+func TestLoadRejectsDuplicateVersions(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+	}{
+		{"two up files", []string{"000001_a.up.sql", "000001_b.up.sql"}},
+		{"two down files", []string{"000001_a.up.sql", "000001_a.down.sql", "000001_b.down.sql"}},
+		{"up and down named differently", []string{"000001_a.up.sql", "000001_b.down.sql"}},
+		{"same version written differently", []string{"000001_a.up.sql", "1_a.up.sql"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeMigrations(t, tt.files...))
+			if !errors.Is(err, ErrDuplicateVersion) {
+				t.Fatalf("got %v, want ErrDuplicateVersion", err)
+			}
+		})
+	}
+}
+
+func TestLoadRequiresUpFile(t *testing.T) {
+	if _, err := Load(writeMigrations(t, "000001_a.down.sql")); err == nil {
+		t.Fatal("expected an error for a migration without an up file")
+	}
+}
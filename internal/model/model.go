@@ -1,6 +1,10 @@
 package model
 
-import "time"
+import (
+	"time"
+
+	"fifthOne/internal/payment"
+)
 
 type Event struct {
 	ID                    int       `db:"id" json:"id"`
@@ -11,17 +15,138 @@ type Event struct {
 	Location              string    `db:"location,omitempty" json:"location,omitempty"`
 	Capacity              int       `db:"capacity" json:"capacity"`
 	PaymentTimeoutMinutes int       `db:"payment_timeout_minutes" json:"payment_timeout_minutes"`
-	CreatedAt             time.Time `db:"created_at" json:"created_at"`
-	UpdatedAt             time.Time `db:"updated_at" json:"updated_at"`
+	// CancellationDeadlineHours is how long before StartTime a confirmed registration
+	// can still be canceled by the attendee; 0 means right up to the start.
+	CancellationDeadlineHours int    `db:"cancellation_deadline_hours" json:"cancellation_deadline_hours"`
+	OrganizerID               string `db:"organizer_id" json:"organizer_id,omitempty"`
+	// Price is in minor currency units; 0 means the event is free and needs no payment.
+	Price    int64  `db:"price" json:"price"`
+	Currency string `db:"currency" json:"currency"`
+	// RefundPolicy applies when the attendee cancels a paid registration; cancellations by an
+	// admin and payments that arrive too late are always refunded in full.
+	RefundPolicy         RefundPolicyType `db:"refund_policy" json:"refund_policy"`
+	RefundFullHours      int              `db:"refund_full_hours" json:"refund_full_hours"`
+	RefundPartialPercent int              `db:"refund_partial_percent" json:"refund_partial_percent"`
+	CreatedAt            time.Time        `db:"created_at" json:"created_at"`
+	UpdatedAt            time.Time        `db:"updated_at" json:"updated_at"`
+}
+
+func (e *Event) IsPaid() bool {
+	return e.Price > 0
+}
+
+// EventUpdate holds the fields of an event to change; nil fields are kept.
+type EventUpdate struct {
+	Name        *string
+	Description *string
+	Location    *string
+	StartTime   *time.Time
+	EndTime     *time.Time
+}
+
+// EventSort is a sort key of the event list; a leading "-" sorts descending.
+type EventSort string
+
+const (
+	SortStartTime     EventSort = "start_time"
+	SortStartTimeDesc EventSort = "-start_time"
+	SortCreatedAt     EventSort = "created_at"
+	SortCreatedAtDesc EventSort = "-created_at"
+	SortName          EventSort = "name"
+	SortNameDesc      EventSort = "-name"
+)
+
+// EventFilter selects a page of the event list. Zero fields do not filter.
+type EventFilter struct {
+	// StartFrom and StartTo bound StartTime, both inclusive.
+	StartFrom *time.Time
+	StartTo   *time.Time
+	// Location matches a substring, case-insensitively.
+	Location string
+	// HasSeats keeps events with a free seat.
+	HasSeats bool
+	// Upcoming keeps events that have not started, Past those that have; at most one is set.
+	Upcoming bool
+	Past     bool
+	Sort     EventSort
+	Limit    int
+	// Cursor is the NextCursor of the previous page, for the same filter and sort.
+	Cursor string
+}
+
+// EventSeats is an event with its seats taken by pending and confirmed registrations and the
+// length of its waitlist.
+type EventSeats struct {
+	Event
+	Taken      int
+	Waitlisted int
+}
+
+type EventPage struct {
+	Events []EventSeats
+	// Total is the number of events matching the filter on all pages.
+	Total int
+	// NextCursor fetches the next page; empty on the last one.
+	NextCursor string
 }
 
 type Registration struct {
-	ID        int       `db:"id" json:"id"`
-	EventID   int       `db:"event_id" json:"event_id"`
-	FullName  string    `db:"full_name" json:"full_name"`
-	Email     string    `db:"email,omitempty" json:"email,omitempty"`
-	Phone     string    `db:"phone,omitempty" json:"phone,omitempty"`
-	Status    string    `db:"status" json:"status"`
-	CreatedAt time.Time `db:"created_at" json:"created_at"`
-	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+	ID        int                `db:"id" json:"id"`
+	EventID   int                `db:"event_id" json:"event_id"`
+	FullName  string             `db:"full_name" json:"full_name"`
+	Email     string             `db:"email,omitempty" json:"email,omitempty"`
+	Phone     string             `db:"phone,omitempty" json:"phone,omitempty"`
+	Status    RegistrationStatus `db:"status" json:"status"`
+	CreatedAt time.Time          `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time          `db:"updated_at" json:"updated_at"`
+	// ExpiresAt is when a pending registration is canceled for non-payment.
+	ExpiresAt *time.Time `db:"expires_at" json:"expires_at,omitempty"`
+	// ExpiryGeneration changes whenever ExpiresAt is set anew; expiries scheduled for an older
+	// generation are stale.
+	ExpiryGeneration int64 `db:"expiry_generation" json:"-"`
+	// NotifyChannel is where the attendee gets notifications; Phone is in E.164.
+	NotifyChannel NotifyChannel `db:"notify_channel" json:"notify_channel"`
+
+	WaitlistPosition int `db:"-" json:"waitlist_position,omitempty"`
+	// ConfirmationToken is only set right after the token is issued; the database keeps its hash.
+	ConfirmationToken string    `db:"-" json:"-"`
+	TokenExpiresAt    time.Time `db:"-" json:"-"`
+	// Refund is the refund owed for the registration's payment, if any.
+	Refund *Refund `db:"-" json:"refund,omitempty"`
+}
+
+type Actor string
+
+const (
+	ActorUser   Actor = "user"
+	ActorAdmin  Actor = "admin"
+	ActorWorker Actor = "worker"
+	// ActorProvider marks changes driven by payment provider webhooks.
+	ActorProvider Actor = "provider"
+)
+
+// RegistrationEvent is one entry of a registration's status history.
+// OldStatus is empty for the entry recorded when the registration is created.
+type RegistrationEvent struct {
+	ID             int64              `db:"id" json:"id"`
+	RegistrationID int64              `db:"registration_id" json:"registration_id"`
+	OldStatus      RegistrationStatus `db:"old_status" json:"old_status,omitempty"`
+	NewStatus      RegistrationStatus `db:"new_status" json:"new_status"`
+	Actor          Actor              `db:"actor" json:"actor"`
+	Reason         string             `db:"reason" json:"reason,omitempty"`
+	CreatedAt      time.Time          `db:"created_at" json:"created_at"`
+}
+
+// Payment is a provider payment intent created for a registration of a paid event.
+type Payment struct {
+	ID               int64          `db:"id" json:"id"`
+	RegistrationID   int64          `db:"registration_id" json:"registration_id"`
+	Provider         string         `db:"provider" json:"provider"`
+	ProviderIntentID string         `db:"provider_intent_id" json:"provider_intent_id"`
+	Amount           int64          `db:"amount" json:"amount"`
+	Currency         string         `db:"currency" json:"currency"`
+	Status           payment.Status `db:"status" json:"status"`
+	CheckoutURL      string         `db:"checkout_url" json:"checkout_url,omitempty"`
+	CreatedAt        time.Time      `db:"created_at" json:"created_at"`
+	UpdatedAt        time.Time      `db:"updated_at" json:"updated_at"`
 }
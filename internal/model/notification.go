@@ -0,0 +1,42 @@
+package model
+
+import "time"
+
+// NotifyChannel is how an attendee is notified: a registration chooses NotifyEmail, NotifySMS or
+// NotifyBoth, a queued notification goes out through NotifyEmail or NotifySMS.
+type NotifyChannel string
+
+const (
+	NotifyEmail NotifyChannel = "email"
+	NotifySMS   NotifyChannel = "sms"
+	NotifyBoth  NotifyChannel = "both"
+)
+
+type NotificationStatus string
+
+const (
+	NotificationPending NotificationStatus = "pending"
+	NotificationSent    NotificationStatus = "sent"
+	// NotificationFailed is final: the dispatcher has run out of attempts.
+	NotificationFailed NotificationStatus = "failed"
+	// NotificationSkipped is final: the recipient has opted out of the notification's category.
+	NotificationSkipped NotificationStatus = "skipped"
+)
+
+type Notification struct {
+	ID int64 `db:"id" json:"id"`
+	// RegistrationID is 0 for notifications that do not belong to a registration.
+	RegistrationID int64         `db:"registration_id" json:"registration_id,omitempty"`
+	Type           string        `db:"type" json:"type"`
+	Channel        NotifyChannel `db:"channel" json:"channel"`
+	// Recipient is an email address, or an E.164 phone number for NotifySMS.
+	Recipient string `db:"recipient" json:"recipient"`
+	// Payload is the JSON-encoded template data.
+	Payload       []byte             `db:"payload" json:"-"`
+	Status        NotificationStatus `db:"status" json:"status"`
+	Attempts      int                `db:"attempts" json:"attempts"`
+	LastError     string             `db:"last_error" json:"last_error,omitempty"`
+	NextAttemptAt time.Time          `db:"next_attempt_at" json:"next_attempt_at"`
+	CreatedAt     time.Time          `db:"created_at" json:"created_at"`
+	SentAt        *time.Time         `db:"sent_at" json:"sent_at,omitempty"`
+}
@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+// OutboxTopicRegistrationExpiry carries a dto.RegistrationOperateMessage that cancels the
+// registration if it is still pending at deliver_at.
+const OutboxTopicRegistrationExpiry = "registration.expiry"
+
+type OutboxMessage struct {
+	ID            int64      `db:"id" json:"id"`
+	Topic         string     `db:"topic" json:"topic"`
+	Payload       []byte     `db:"payload" json:"payload"`
+	DeliverAt     time.Time  `db:"deliver_at" json:"deliver_at"`
+	Attempts      int        `db:"attempts" json:"attempts"`
+	LastError     string     `db:"last_error" json:"last_error,omitempty"`
+	NextAttemptAt time.Time  `db:"next_attempt_at" json:"next_attempt_at"`
+	CreatedAt     time.Time  `db:"created_at" json:"created_at"`
+	SentAt        *time.Time `db:"sent_at" json:"sent_at,omitempty"`
+}
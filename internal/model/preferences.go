@@ -0,0 +1,56 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// NotificationCategory groups notification types for opt-outs.
+type NotificationCategory string
+
+const (
+	// CategoryTransactional covers messages about the attendee's own bookings; it cannot be opted out of.
+	CategoryTransactional  NotificationCategory = "transactional"
+	CategoryReminders      NotificationCategory = "reminders"
+	CategoryMarketing      NotificationCategory = "marketing"
+	CategoryWaitlistOffers NotificationCategory = "waitlist_offers"
+)
+
+// OptionalCategories are the categories a contact can opt out of.
+var OptionalCategories = []NotificationCategory{CategoryReminders, CategoryMarketing, CategoryWaitlistOffers}
+
+func (c NotificationCategory) Optional() bool {
+	switch c {
+	case CategoryReminders, CategoryMarketing, CategoryWaitlistOffers:
+		return true
+	}
+	return false
+}
+
+// ContactPreferences are the opt-outs of one email address or phone number.
+type ContactPreferences struct {
+	Contact              string    `db:"contact" json:"contact"`
+	OptOutReminders      bool      `db:"opt_out_reminders" json:"opt_out_reminders"`
+	OptOutMarketing      bool      `db:"opt_out_marketing" json:"opt_out_marketing"`
+	OptOutWaitlistOffers bool      `db:"opt_out_waitlist_offers" json:"opt_out_waitlist_offers"`
+	UpdatedAt            time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// OptedOut reports whether the contact does not want notifications of category c.
+func (p *ContactPreferences) OptedOut(c NotificationCategory) bool {
+	switch c {
+	case CategoryReminders:
+		return p.OptOutReminders
+	case CategoryMarketing:
+		return p.OptOutMarketing
+	case CategoryWaitlistOffers:
+		return p.OptOutWaitlistOffers
+	}
+	return false
+}
+
+// NormalizeContact is the key under which preferences are stored: email addresses are
+// case-insensitive, phone numbers are expected in E.164 already.
+func NormalizeContact(contact string) string {
+	return strings.ToLower(strings.TrimSpace(contact))
+}
@@ -0,0 +1,68 @@
+package model
+
+import "time"
+
+type RefundPolicyType string
+
+const (
+	// RefundNone keeps the whole payment.
+	RefundNone RefundPolicyType = "none"
+	// RefundFull returns the whole payment whenever the attendee cancels.
+	RefundFull RefundPolicyType = "full"
+	// RefundTiered returns the whole payment until RefundFullHours before StartTime
+	// and RefundPartialPercent of it afterwards.
+	RefundTiered RefundPolicyType = "tiered"
+)
+
+func (p RefundPolicyType) Valid() bool {
+	switch p {
+	case RefundNone, RefundFull, RefundTiered:
+		return true
+	}
+	return false
+}
+
+// AttendeeRefundAmount is how much of paid is returned when the attendee cancels at the given moment.
+func (e *Event) AttendeeRefundAmount(paid int64, at time.Time) int64 {
+	switch e.RefundPolicy {
+	case RefundFull:
+		return paid
+	case RefundTiered:
+		fullUntil := e.StartTime.Add(-time.Duration(e.RefundFullHours) * time.Hour)
+		if !at.After(fullUntil) {
+			return paid
+		}
+		return paid * int64(e.RefundPartialPercent) / 100
+	default:
+		return 0
+	}
+}
+
+type RefundStatus string
+
+const (
+	RefundPending   RefundStatus = "pending"
+	RefundSucceeded RefundStatus = "succeeded"
+	RefundFailed    RefundStatus = "failed"
+)
+
+// Refund is money owed back for a payment. Pending refunds are executed through the payment
+// provider by the refund job in the worker.
+type Refund struct {
+	ID               int64        `db:"id" json:"id"`
+	RegistrationID   int64        `db:"registration_id" json:"registration_id"`
+	PaymentID        int64        `db:"payment_id" json:"payment_id"`
+	Amount           int64        `db:"amount" json:"amount"`
+	Currency         string       `db:"currency" json:"currency"`
+	Status           RefundStatus `db:"status" json:"status"`
+	Reason           string       `db:"reason" json:"reason,omitempty"`
+	ProviderRefundID string       `db:"provider_refund_id" json:"provider_refund_id,omitempty"`
+	Attempts         int          `db:"attempts" json:"attempts"`
+	LastError        string       `db:"last_error" json:"last_error,omitempty"`
+	NextAttemptAt    time.Time    `db:"next_attempt_at" json:"next_attempt_at"`
+	CreatedAt        time.Time    `db:"created_at" json:"created_at"`
+	UpdatedAt        time.Time    `db:"updated_at" json:"updated_at"`
+
+	// ProviderIntentID is filled in when the refund is claimed for execution.
+	ProviderIntentID string `db:"-" json:"-"`
+}
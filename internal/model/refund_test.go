@@ -0,0 +1,63 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAttendeeRefundAmount(t *testing.T) {
+	start := time.Date(2026, 6, 1, 18, 0, 0, 0, time.UTC)
+	cutoff := start.Add(-48 * time.Hour)
+	const paid = 10_000
+
+	tiered := func(percent int) *Event {
+		return &Event{
+			StartTime:            start,
+			RefundPolicy:         RefundTiered,
+			RefundFullHours:      48,
+			RefundPartialPercent: percent,
+		}
+	}
+
+	tests := []struct {
+		name  string
+		event *Event
+		at    time.Time
+		want  int64
+	}{
+		{"tiered, well before the cutoff", tiered(50), cutoff.Add(-24 * time.Hour), paid},
+		{"tiered, just before the cutoff", tiered(50), cutoff.Add(-time.Nanosecond), paid},
+		{"tiered, exactly at the cutoff", tiered(50), cutoff, paid},
+		{"tiered, just after the cutoff", tiered(50), cutoff.Add(time.Nanosecond), 5_000},
+		{"tiered, after the start", tiered(50), start.Add(time.Hour), 5_000},
+		{"tiered 0%, after the cutoff", tiered(0), cutoff.Add(time.Minute), 0},
+		{"tiered 0%, before the cutoff", tiered(0), cutoff.Add(-time.Minute), paid},
+		{"tiered 100%, after the cutoff", tiered(100), cutoff.Add(time.Minute), paid},
+		{"tiered, odd amount rounds down", tiered(33), cutoff.Add(time.Minute), 3_300},
+		{"tiered with no full window", &Event{StartTime: start, RefundPolicy: RefundTiered, RefundPartialPercent: 25}, start, paid},
+		{"tiered with no full window, after the start", &Event{StartTime: start, RefundPolicy: RefundTiered, RefundPartialPercent: 25}, start.Add(time.Second), 2_500},
+		{"full, after the start", &Event{StartTime: start, RefundPolicy: RefundFull}, start.Add(time.Hour), paid},
+		{"none, long before the start", &Event{StartTime: start, RefundPolicy: RefundNone}, start.Add(-30 * 24 * time.Hour), 0},
+		{"unknown policy", &Event{StartTime: start, RefundPolicy: "bogus"}, cutoff, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.event.AttendeeRefundAmount(paid, tt.at); got != tt.want {
+				t.Errorf("AttendeeRefundAmount = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRefundPolicyValid(t *testing.T) {
+	for _, p := range []RefundPolicyType{RefundNone, RefundFull, RefundTiered} {
+		if !p.Valid() {
+			t.Errorf("%q reported invalid", p)
+		}
+	}
+	for _, p := range []RefundPolicyType{"", "partial", "FULL"} {
+		if p.Valid() {
+			t.Errorf("%q reported valid", p)
+		}
+	}
+}
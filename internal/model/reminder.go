@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// Reminder is a reminder due to the attendee of a confirmed registration Offset before the event starts.
+type Reminder struct {
+	RegistrationID int64         `json:"registration_id"`
+	EventID        int64         `json:"event_id"`
+	Email          string        `json:"email"`
+	Phone          string        `json:"phone,omitempty"`
+	NotifyChannel  NotifyChannel `json:"notify_channel"`
+	EventName      string        `json:"event_name"`
+	Location       string        `json:"location,omitempty"`
+	StartTime      time.Time     `json:"start_time"`
+	Offset         time.Duration `json:"offset"`
+}
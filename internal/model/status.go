@@ -0,0 +1,58 @@
+package model
+
+import "fmt"
+
+type RegistrationStatus string
+
+const (
+	StatusPending    RegistrationStatus = "pending"
+	StatusConfirmed  RegistrationStatus = "confirmed"
+	StatusCanceled   RegistrationStatus = "canceled"
+	StatusExpired    RegistrationStatus = "expired"
+	StatusWaitlisted RegistrationStatus = "waitlisted"
+	StatusCheckedIn  RegistrationStatus = "checked_in"
+	StatusRefunded   RegistrationStatus = "refunded"
+)
+
+// registrationTransitions lists every status a registration may move to from a given status.
+// Statuses missing from the map are terminal. Expired is reserved for payment timeouts,
+// canceled for explicit attendee or admin action. Refunded is set once the payment provider
+// reports the money returned, including for payments that arrived after the registration expired.
+var registrationTransitions = map[RegistrationStatus][]RegistrationStatus{
+	StatusWaitlisted: {StatusPending, StatusCanceled},
+	StatusPending:    {StatusConfirmed, StatusExpired, StatusCanceled},
+	StatusConfirmed:  {StatusCanceled, StatusCheckedIn, StatusRefunded},
+	StatusCanceled:   {StatusRefunded},
+	StatusExpired:    {StatusRefunded},
+}
+
+func (s RegistrationStatus) Valid() bool {
+	switch s {
+	case StatusPending, StatusConfirmed, StatusCanceled, StatusExpired, StatusWaitlisted, StatusCheckedIn, StatusRefunded:
+		return true
+	}
+	return false
+}
+
+func (s RegistrationStatus) CanTransitionTo(next RegistrationStatus) bool {
+	for _, allowed := range registrationTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// HoldsSeat reports whether a registration in this status counts against event capacity.
+func (s RegistrationStatus) HoldsSeat() bool {
+	return s == StatusPending || s == StatusConfirmed || s == StatusCheckedIn
+}
+
+type InvalidTransitionError struct {
+	From RegistrationStatus
+	To   RegistrationStatus
+}
+
+func (e *InvalidTransitionError) Error() string {
+	return fmt.Sprintf("invalid registration status transition: %s -> %s", e.From, e.To)
+}
@@ -0,0 +1,69 @@
+package model
+
+import "testing"
+
+func TestCanTransitionTo(t *testing.T) {
+	all := []RegistrationStatus{
+		StatusWaitlisted, StatusPending, StatusConfirmed, StatusCheckedIn,
+		StatusCanceled, StatusExpired, StatusRefunded,
+	}
+	allowed := map[[2]RegistrationStatus]bool{
+		{StatusWaitlisted, StatusPending}:  true,
+		{StatusWaitlisted, StatusCanceled}: true,
+		{StatusPending, StatusConfirmed}:   true,
+		{StatusPending, StatusExpired}:     true,
+		{StatusPending, StatusCanceled}:    true,
+		{StatusConfirmed, StatusCanceled}:  true,
+		{StatusConfirmed, StatusCheckedIn}: true,
+		{StatusConfirmed, StatusRefunded}:  true,
+		{StatusCanceled, StatusRefunded}:   true,
+		{StatusExpired, StatusRefunded}:    true,
+	}
+
+	// every pair, so that an edge added to the table without updating this test fails too
+	for _, from := range all {
+		for _, to := range all {
+			want := allowed[[2]RegistrationStatus{from, to}]
+			if got := from.CanTransitionTo(to); got != want {
+				t.Errorf("%s -> %s: got %t, want %t", from, to, got, want)
+			}
+		}
+	}
+}
+
+func TestTerminalStatuses(t *testing.T) {
+	for _, s := range []RegistrationStatus{StatusCheckedIn, StatusRefunded} {
+		for _, to := range []RegistrationStatus{StatusPending, StatusConfirmed, StatusCanceled, StatusExpired} {
+			if s.CanTransitionTo(to) {
+				t.Errorf("%s is terminal but may move to %s", s, to)
+			}
+		}
+	}
+}
+
+func TestUnknownStatus(t *testing.T) {
+	unknown := RegistrationStatus("paid")
+	if unknown.Valid() {
+		t.Error("unknown status reported as valid")
+	}
+	if unknown.CanTransitionTo(StatusConfirmed) || StatusPending.CanTransitionTo(unknown) {
+		t.Error("transition involving an unknown status allowed")
+	}
+}
+
+func TestHoldsSeat(t *testing.T) {
+	tests := map[RegistrationStatus]bool{
+		StatusPending:    true,
+		StatusConfirmed:  true,
+		StatusCheckedIn:  true,
+		StatusWaitlisted: false,
+		StatusCanceled:   false,
+		StatusExpired:    false,
+		StatusRefunded:   false,
+	}
+	for s, want := range tests {
+		if got := s.HoldsSeat(); got != want {
+			t.Errorf("%s.HoldsSeat() = %t, want %t", s, got, want)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"fifthOne/internal/model"
+	"fifthOne/internal/repo"
+	"fifthOne/pkg/token"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	defaultDispatchInterval = 2 * time.Second
+	defaultDispatchBatch    = 20
+	defaultMaxAttempts      = 6
+	// dispatchLease keeps a claimed notification away from other dispatchers while it is sent.
+	dispatchLease      = time.Minute
+	maxDispatchBackoff = time.Hour
+)
+
+// Dispatcher delivers queued notifications through a Notifier that actually sends them. A
+// notification of an optional category is skipped if the recipient has opted out of it. Failed
+// sends are retried with a growing pause; after maxAttempts the notification is marked failed.
+// Delivery is at-least-once: a dispatcher that dies between sending and MarkNotificationSent sends
+// the message again after the lease. Several dispatchers may run against the same database.
+type Dispatcher struct {
+	repo        repo.Repository
+	notifier    Notifier
+	unsubscribe *Unsubscribe
+	linkSecret  string
+	log         *zerolog.Logger
+	interval    time.Duration
+	batch       int
+	maxAttempts int
+
+	done   chan struct{}
+	cancel context.CancelFunc
+}
+
+// NewDispatcher returns a dispatcher sending through notifier. linkSecret opens the links sealed by
+// Outbox; maxAttempts <= 0 means the default.
+func NewDispatcher(repo repo.Repository, notifier Notifier, unsubscribe *Unsubscribe, linkSecret string, maxAttempts int, log *zerolog.Logger) *Dispatcher {
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+	return &Dispatcher{
+		repo:        repo,
+		notifier:    notifier,
+		unsubscribe: unsubscribe,
+		linkSecret:  linkSecret,
+		log:         log,
+		interval:    defaultDispatchInterval,
+		batch:       defaultDispatchBatch,
+		maxAttempts: maxAttempts,
+		done:        make(chan struct{}),
+	}
+}
+
+func (d *Dispatcher) Start(ctx context.Context) {
+	cctx, cancel := context.WithCancel(ctx)
+	d.cancel = cancel
+
+	d.log.Info().Msg("📬 Notification dispatcher started")
+
+	go func() {
+		defer close(d.done)
+
+		ticker := time.NewTicker(d.interval)
+		defer ticker.Stop()
+
+		for {
+			// a full batch means more notifications are probably waiting
+			if d.runOnce(cctx) == d.batch && cctx.Err() == nil {
+				continue
+			}
+
+			select {
+			case <-cctx.Done():
+				d.log.Info().Msg("🛑 Notification dispatcher stopped by context")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (d *Dispatcher) Stop() {
+	if d.cancel != nil {
+		d.cancel()
+		<-d.done
+	}
+}
+
+// runOnce delivers one batch and returns how many notifications were claimed.
+func (d *Dispatcher) runOnce(ctx context.Context) int {
+	notifications, err := d.repo.ClaimNotifications(ctx, d.batch, dispatchLease)
+	if err != nil {
+		if ctx.Err() == nil {
+			d.log.Error().Err(err).Msg("Failed to claim notifications")
+		}
+		return 0
+	}
+
+	for i := range notifications {
+		if ctx.Err() != nil {
+			// the rest of the batch is picked up again once the lease runs out
+			break
+		}
+		d.deliver(ctx, &notifications[i])
+	}
+	return len(notifications)
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, n *model.Notification) {
+	log := d.log.With().
+		Int64("notification_id", n.ID).
+		Int64("registration_id", n.RegistrationID).
+		Str("type", n.Type).
+		Int("attempt", n.Attempts).
+		Logger()
+
+	// a message that has started going out is finished even during shutdown
+	ctx = context.WithoutCancel(ctx)
+
+	optedOut, err := d.optedOut(ctx, n)
+	if err == nil && optedOut {
+		log.Info().Msg("Notification skipped, the recipient has opted out")
+		if err := d.repo.SkipNotification(ctx, n.ID, "recipient opted out"); err != nil {
+			log.Error().Err(err).Msg("Failed to record skipped notification")
+		}
+		return
+	}
+	if err == nil {
+		err = d.send(ctx, n)
+	}
+	if err == nil {
+		if err := d.repo.MarkNotificationSent(ctx, n.ID); err != nil {
+			log.Error().Err(err).Msg("Notification sent but not marked, it will be sent again")
+		}
+		return
+	}
+
+	var retryAt *time.Time
+	if !errors.Is(err, ErrRender) && !errors.Is(err, ErrNoChannel) && n.Attempts < d.maxAttempts {
+		at := time.Now().Add(backoff(n.Attempts))
+		retryAt = &at
+	}
+
+	if retryAt == nil {
+		log.Error().Err(err).Msg("❌ Notification failed permanently")
+	} else {
+		log.Warn().Err(err).Time("retry_at", *retryAt).Msg("Notification attempt failed")
+	}
+	if err := d.repo.FailNotification(ctx, n.ID, err.Error(), retryAt); err != nil {
+		log.Error().Err(err).Msg("Failed to record notification failure")
+	}
+}
+
+// optedOut checks the preferences of the recipient right before sending, so an opt-out also
+// covers notifications queued earlier.
+func (d *Dispatcher) optedOut(ctx context.Context, n *model.Notification) (bool, error) {
+	category := Type(n.Type).Category()
+	if !category.Optional() {
+		return false, nil
+	}
+	prefs, err := d.repo.GetContactPreferences(ctx, n.Recipient)
+	if err != nil {
+		return false, err
+	}
+	return prefs.OptedOut(category), nil
+}
+
+func (d *Dispatcher) send(ctx context.Context, n *model.Notification) error {
+	var data Data
+	if err := json.Unmarshal(n.Payload, &data); err != nil {
+		return fmt.Errorf("%w: invalid payload: %v", ErrRender, err)
+	}
+	if data.SealedLink != "" {
+		link, err := token.Open(d.linkSecret, data.SealedLink)
+		if err != nil {
+			return fmt.Errorf("%w: link: %v", ErrRender, err)
+		}
+		data.Link = link
+	}
+	data.UnsubscribeURL = d.unsubscribe.Link(n.Recipient, Type(n.Type).Category())
+	return d.notifier.Notify(ctx, Notification{
+		Type:           Type(n.Type),
+		Channel:        n.Channel,
+		To:             n.Recipient,
+		Data:           data,
+		RegistrationID: n.RegistrationID,
+	})
+}
+
+// backoff doubles from 30 seconds per attempt, capped at maxDispatchBackoff.
+func backoff(attempt int) time.Duration {
+	d := 30 * time.Second
+	for i := 1; i < attempt && d < maxDispatchBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxDispatchBackoff)
+}
@@ -0,0 +1,74 @@
+package notify
+
+import (
+	"context"
+	"testing"
+
+	"fifthOne/internal/model"
+	"fifthOne/internal/repo"
+
+	"github.com/rs/zerolog"
+)
+
+// dispatchRepo records how the dispatcher closes notifications; the recipient has opted out of everything.
+type dispatchRepo struct {
+	repo.Repository
+	sent, skipped []int64
+}
+
+func (r *dispatchRepo) GetContactPreferences(_ context.Context, contact string) (*model.ContactPreferences, error) {
+	return &model.ContactPreferences{
+		Contact:              contact,
+		OptOutReminders:      true,
+		OptOutMarketing:      true,
+		OptOutWaitlistOffers: true,
+	}, nil
+}
+
+func (r *dispatchRepo) MarkNotificationSent(_ context.Context, id int64) error {
+	r.sent = append(r.sent, id)
+	return nil
+}
+
+func (r *dispatchRepo) SkipNotification(_ context.Context, id int64, _ string) error {
+	r.skipped = append(r.skipped, id)
+	return nil
+}
+
+func TestCategory(t *testing.T) {
+	for _, typ := range Types {
+		want := model.CategoryTransactional
+		if typ == TypeReminder {
+			want = model.CategoryReminders
+		}
+		if got := typ.Category(); got != want {
+			t.Errorf("%s: category %s, want %s", typ, got, want)
+		}
+	}
+}
+
+// A waitlister promoted to pending holds a seat until the payment timeout, so the offer with the
+// link to claim it goes out even if they opted out of every optional category.
+func TestDispatchIgnoresOptOutForPromotion(t *testing.T) {
+	store := &dispatchRepo{}
+	notifier := &recordingNotifier{}
+	log := zerolog.Nop()
+	d := NewDispatcher(store, notifier, NewUnsubscribe("https://eventbooker.example", "s"), "link-secret", 0, &log)
+
+	for _, n := range []model.Notification{
+		{ID: 1, Type: string(TypePromoted), Recipient: "a@example.com", Payload: []byte(`{"event_name":"Go Meetup"}`)},
+		{ID: 2, Type: string(TypeReminder), Recipient: "a@example.com", Payload: []byte(`{"event_name":"Go Meetup"}`)},
+	} {
+		d.deliver(context.Background(), &n)
+	}
+
+	if len(store.sent) != 1 || store.sent[0] != 1 {
+		t.Errorf("sent %v, want the promotion [1]", store.sent)
+	}
+	if len(store.skipped) != 1 || store.skipped[0] != 2 {
+		t.Errorf("skipped %v, want the reminder [2]", store.skipped)
+	}
+	if len(notifier.sent) != 1 || notifier.sent[0].Type != TypePromoted {
+		t.Errorf("notifier got %+v, want only the promotion", notifier.sent)
+	}
+}
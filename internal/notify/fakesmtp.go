@@ -0,0 +1,168 @@
+package notify
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+const fakeSMTPIdleTimeout = time.Minute
+
+// ReceivedMail is a message accepted by FakeSMTP.
+type ReceivedMail struct {
+	From    string
+	To      []string
+	Subject string
+	Data    []byte
+}
+
+// FakeSMTP is a minimal SMTP server that accepts every message and keeps it in memory instead of
+// delivering it. Point an SMTP channel with TLS "none" and no credentials at it to check
+// notifications locally or in tests.
+type FakeSMTP struct {
+	ln  net.Listener
+	log *zerolog.Logger
+
+	mu   sync.Mutex
+	mail []ReceivedMail
+
+	wg sync.WaitGroup
+}
+
+// ListenFakeSMTP starts serving on addr, e.g. "127.0.0.1:0" for a random port.
+func ListenFakeSMTP(addr string, log *zerolog.Logger) (*FakeSMTP, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for smtp: %w", err)
+	}
+
+	s := &FakeSMTP{ln: ln, log: log}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+func (s *FakeSMTP) Addr() string {
+	return s.ln.Addr().String()
+}
+
+// Messages returns the messages received so far, oldest first.
+func (s *FakeSMTP) Messages() []ReceivedMail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]ReceivedMail(nil), s.mail...)
+}
+
+// Close stops accepting connections and waits for open sessions to end.
+func (s *FakeSMTP) Close() error {
+	err := s.ln.Close()
+	s.wg.Wait()
+	return err
+}
+
+func (s *FakeSMTP) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				s.log.Error().Err(err).Msg("Fake SMTP server stopped")
+			}
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer conn.Close()
+			s.session(conn)
+		}()
+	}
+}
+
+func (s *FakeSMTP) session(conn net.Conn) {
+	tp := textproto.NewConn(conn)
+	reply := func(code int, msg string) bool {
+		return tp.PrintfLine("%d %s", code, msg) == nil
+	}
+
+	if !reply(220, "fake-smtp ready") {
+		return
+	}
+
+	var from string
+	var to []string
+	for {
+		_ = conn.SetDeadline(time.Now().Add(fakeSMTPIdleTimeout))
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			from, to = "", nil
+			reply(250, "fake-smtp")
+		case "MAIL":
+			from = envelopeAddress(arg)
+			reply(250, "OK")
+		case "RCPT":
+			to = append(to, envelopeAddress(arg))
+			reply(250, "OK")
+		case "DATA":
+			if from == "" || len(to) == 0 {
+				reply(503, "need MAIL and RCPT first")
+				continue
+			}
+			if !reply(354, "end data with <CR><LF>.<CR><LF>") {
+				return
+			}
+			data, err := io.ReadAll(tp.DotReader())
+			if err != nil {
+				return
+			}
+			s.store(from, to, data)
+			from, to = "", nil
+			reply(250, "OK: queued")
+		case "RSET":
+			from, to = "", nil
+			reply(250, "OK")
+		case "NOOP":
+			reply(250, "OK")
+		case "QUIT":
+			reply(221, "bye")
+			return
+		default:
+			reply(502, "command not implemented")
+		}
+	}
+}
+
+func (s *FakeSMTP) store(from string, to []string, data []byte) {
+	m := ReceivedMail{From: from, To: to, Data: data}
+	if parsed, err := mail.ReadMessage(bufio.NewReader(strings.NewReader(string(data)))); err == nil {
+		m.Subject = decodeHeader(parsed.Header.Get("Subject"))
+	}
+
+	s.mu.Lock()
+	s.mail = append(s.mail, m)
+	s.mu.Unlock()
+
+	s.log.Info().Msgf("📨 Fake SMTP принял письмо для %s: %s", strings.Join(to, ", "), m.Subject)
+}
+
+// envelopeAddress extracts the address from "FROM:<a@b>" or "TO:<a@b>".
+func envelopeAddress(arg string) string {
+	_, addr, _ := strings.Cut(arg, ":")
+	addr, _, _ = strings.Cut(strings.TrimSpace(addr), " ")
+	return strings.Trim(addr, "<>")
+}
@@ -0,0 +1,86 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// ReceivedText is a message accepted by FakeText.
+type ReceivedText struct {
+	To         string
+	Text       string
+	ReceivedAt time.Time
+}
+
+// FakeText is a stand-in for an SMS or Telegram gateway: it accepts what HTTPText posts and keeps
+// the messages in memory. Point the http text channel at URL() to check text notifications
+// locally or in tests.
+type FakeText struct {
+	ln     net.Listener
+	server *http.Server
+	log    *zerolog.Logger
+
+	mu       sync.Mutex
+	messages []ReceivedText
+}
+
+// ListenFakeText starts serving on addr, e.g. "127.0.0.1:0" for a random port.
+func ListenFakeText(addr string, log *zerolog.Logger) (*FakeText, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for text messages: %w", err)
+	}
+
+	f := &FakeText{ln: ln, log: log}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /messages", f.receive)
+	f.server = &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+
+	go func() {
+		if err := f.server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error().Err(err).Msg("Fake text gateway stopped")
+		}
+	}()
+	return f, nil
+}
+
+// URL is where HTTPText should post messages.
+func (f *FakeText) URL() string {
+	return "http://" + f.ln.Addr().String() + "/messages"
+}
+
+// Messages returns the messages received so far, oldest first.
+func (f *FakeText) Messages() []ReceivedText {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]ReceivedText(nil), f.messages...)
+}
+
+func (f *FakeText) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return f.server.Shutdown(ctx)
+}
+
+func (f *FakeText) receive(w http.ResponseWriter, r *http.Request) {
+	var p TextPayload
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&p); err != nil || p.To == "" || p.Text == "" {
+		http.Error(w, "expected {\"to\": ..., \"text\": ...}", http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	f.messages = append(f.messages, ReceivedText{To: p.To, Text: p.Text, ReceivedAt: time.Now()})
+	f.mu.Unlock()
+
+	f.log.Info().Msgf("📱 Fake text gateway принял сообщение для %s: %s", p.To, p.Text)
+	w.WriteHeader(http.StatusAccepted)
+}
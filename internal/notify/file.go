@@ -0,0 +1,53 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// File writes the subject and plain-text body of every message to a file or to stdout, readable as
+// is. It is meant for development.
+type File struct {
+	mu sync.Mutex
+	w  io.Writer
+	// closer is nil for stdout, which is not ours to close.
+	closer io.Closer
+}
+
+// NewFile appends messages to the file at path, or writes them to stdout if path is empty.
+func NewFile(path string) (*File, error) {
+	if path == "" {
+		return &File{w: os.Stdout}, nil
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open notification file: %w", err)
+	}
+	return &File{w: f, closer: f}, nil
+}
+
+func (f *File) Send(_ context.Context, msg Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	// text messages have no subject
+	subject := ""
+	if msg.Subject != "" {
+		subject = "Subject: " + msg.Subject + "\n\n"
+	}
+	if _, err := fmt.Fprintf(f.w, "----- %s -> %s -----\n%s%s\n",
+		time.Now().Format(time.RFC3339), msg.To, subject, msg.Text); err != nil {
+		return fmt.Errorf("failed to write notification: %w", err)
+	}
+	return nil
+}
+
+func (f *File) Close() error {
+	if f.closer == nil {
+		return nil
+	}
+	return f.closer.Close()
+}
@@ -0,0 +1,72 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const defaultHTTPTextTimeout = 10 * time.Second
+
+type HTTPTextConfig struct {
+	// URL receives one POST per message.
+	URL string
+	// Token is sent as "Authorization: Bearer <token>" if set.
+	Token   string
+	Timeout time.Duration
+}
+
+// TextPayload is the body HTTPText posts: the E.164 phone number and the message text.
+type TextPayload struct {
+	To   string `json:"to"`
+	Text string `json:"text"`
+}
+
+// HTTPText sends text messages to a phone through an HTTP gateway: an SMS provider, or a Telegram
+// bot that maps phone numbers to chats. Any 2xx answer means the gateway has taken the message.
+type HTTPText struct {
+	cfg    HTTPTextConfig
+	client *http.Client
+}
+
+func NewHTTPText(cfg HTTPTextConfig) (*HTTPText, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("text gateway url is required")
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultHTTPTextTimeout
+	}
+	return &HTTPText{cfg: cfg, client: &http.Client{Timeout: cfg.Timeout}}, nil
+}
+
+func (h *HTTPText) Send(ctx context.Context, msg Message) error {
+	body, err := json.Marshal(TextPayload{To: msg.To, Text: msg.Text})
+	if err != nil {
+		return fmt.Errorf("failed to encode text message: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build text gateway request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if h.cfg.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+h.cfg.Token)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("text gateway request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("text gateway answered %s: %s", resp.Status, bytes.TrimSpace(detail))
+	}
+	return nil
+}
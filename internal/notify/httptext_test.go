@@ -0,0 +1,68 @@
+package notify
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"fifthOne/internal/model"
+
+	"github.com/rs/zerolog"
+)
+
+func TestNotifyThroughFakeText(t *testing.T) {
+	log := zerolog.Nop()
+	gateway, err := ListenFakeText("127.0.0.1:0", &log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = gateway.Close() })
+
+	sms, err := NewHTTPText(HTTPTextConfig{URL: gateway.URL(), Token: "gateway-token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	notifier := New(loadTemplates(t), nil, sms, &log)
+
+	err = notifier.Notify(context.Background(), Notification{
+		Type:    TypePending,
+		Channel: model.NotifySMS,
+		To:      "+79991234567",
+		Data:    Data{EventName: "Go Meetup", TimeoutMinutes: 15, Link: "https://eventbooker.example/confirm"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received := gateway.Messages()
+	if len(received) != 1 {
+		t.Fatalf("got %d messages, want 1", len(received))
+	}
+	got := received[0]
+	if got.To != "+79991234567" {
+		t.Errorf("to %q", got.To)
+	}
+	if !strings.Contains(got.Text, "«Go Meetup»") || !strings.Contains(got.Text, "https://eventbooker.example/confirm") {
+		t.Errorf("text %q", got.Text)
+	}
+}
+
+func TestFakeTextRejectsIncompleteMessage(t *testing.T) {
+	log := zerolog.Nop()
+	gateway, err := ListenFakeText("127.0.0.1:0", &log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = gateway.Close() })
+
+	sms, err := NewHTTPText(HTTPTextConfig{URL: gateway.URL()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sms.Send(context.Background(), Message{To: "+79991234567"}); err == nil {
+		t.Fatal("expected the gateway to reject a message without text")
+	}
+	if n := len(gateway.Messages()); n != 0 {
+		t.Fatalf("gateway kept %d messages", n)
+	}
+}
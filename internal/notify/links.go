@@ -0,0 +1,40 @@
+package notify
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ConfirmationLink builds the one-click link to the attendee page that confirms a pending registration.
+func ConfirmationLink(publicURL string, eventID int64, token string) string {
+	q := url.Values{}
+	q.Set("event", strconv.FormatInt(eventID, 10))
+	q.Set("token", token)
+	return strings.TrimRight(publicURL, "/") + "/user?" + q.Encode()
+}
+
+// CheckoutLink builds the link that sends the attendee of a paid event to the payment page.
+// After paying, the gateway returns them to the ConfirmationLink.
+func CheckoutLink(publicURL string, eventID int64, token string) string {
+	q := url.Values{}
+	q.Set("token", token)
+	return fmt.Sprintf("%s/v1/events/%d/checkout?%s", strings.TrimRight(publicURL, "/"), eventID, q.Encode())
+}
+
+// PaymentReturnLink is where the payment page sends the attendee back to once they have paid.
+func PaymentReturnLink(publicURL string, eventID int64) string {
+	q := url.Values{}
+	q.Set("event", strconv.FormatInt(eventID, 10))
+	q.Set("payment", "done")
+	return strings.TrimRight(publicURL, "/") + "/user?" + q.Encode()
+}
+
+// BookingLink picks the link sent to a pending attendee: checkout for paid events, confirmation otherwise.
+func BookingLink(publicURL string, eventID int64, token string, paid bool) string {
+	if paid {
+		return CheckoutLink(publicURL, eventID, token)
+	}
+	return ConfirmationLink(publicURL, eventID, token)
+}
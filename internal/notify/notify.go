@@ -0,0 +1,165 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"fifthOne/internal/model"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	ChannelSMTP = "smtp"
+	ChannelFile = "file"
+	// ChannelHTTP sends text messages through an SMS or Telegram bot gateway, see HTTPText.
+	ChannelHTTP = "http"
+)
+
+// Type selects the templates of a notification. The registration statuses double as types.
+type Type string
+
+const (
+	TypePending             Type = "pending"
+	TypeWaitlisted          Type = "waitlisted"
+	TypeConfirmed           Type = "confirmed"
+	TypeExpired             Type = "expired"
+	TypeCanceled            Type = "canceled"
+	TypeCanceledByOrganizer Type = "canceled_by_organizer"
+	TypeRefunded            Type = "refunded"
+	TypePromoted            Type = "promoted"
+	TypeReminder            Type = "reminder"
+)
+
+// Types lists every notification type; LoadTemplates requires templates for each of them.
+var Types = []Type{
+	TypePending,
+	TypeWaitlisted,
+	TypeConfirmed,
+	TypeExpired,
+	TypeCanceled,
+	TypeCanceledByOrganizer,
+	TypeRefunded,
+	TypePromoted,
+	TypeReminder,
+}
+
+// Category is the opt-out category of the type. Reminders are optional; everything else is about
+// the attendee's own booking. That includes TypePromoted: a promotion already holds a seat for the
+// attendee until the payment timeout, and without the message they could not claim it in time.
+func (t Type) Category() model.NotificationCategory {
+	if t == TypeReminder {
+		return model.CategoryReminders
+	}
+	return model.CategoryTransactional
+}
+
+var (
+	// ErrRender means the notification cannot be rendered, e.g. its type has no templates; sending
+	// it again will not help.
+	ErrRender = errors.New("cannot render notification")
+	// ErrNoChannel means nothing is configured to deliver notifications of that channel.
+	ErrNoChannel = errors.New("notification channel is not configured")
+)
+
+// Data is what the templates can refer to. It is stored as JSON while the notification is queued.
+type Data struct {
+	EventName string `json:"event_name"`
+	// TimeoutMinutes is how long a pending attendee has to confirm or pay.
+	TimeoutMinutes int `json:"timeout_minutes,omitempty"`
+	// Link confirms or pays for a pending registration; empty for other notifications. It carries
+	// the plain confirmation token, so it is only stored as SealedLink.
+	Link       string `json:"-"`
+	SealedLink string `json:"sealed_link,omitempty"`
+	// StartTime and Location are set for reminders.
+	StartTime *time.Time `json:"start_time,omitempty"`
+	Location  string     `json:"location,omitempty"`
+	// UnsubscribeURL goes into the footer. It is added when the message is sent, not stored.
+	UnsubscribeURL string `json:"-"`
+}
+
+type Notification struct {
+	Type Type
+	// Channel is model.NotifyEmail (the default) or model.NotifySMS; To is an email address or
+	// an E.164 phone number accordingly.
+	Channel model.NotifyChannel
+	To      string
+	Data    Data
+	// RegistrationID ties the notification to a registration in the delivery log; 0 if none.
+	RegistrationID int64
+}
+
+// Message is a rendered notification as a channel delivers it. HTML and UnsubscribeURL are optional.
+type Message struct {
+	To             string
+	Subject        string
+	Text           string
+	HTML           string
+	UnsubscribeURL string
+}
+
+// Channel delivers rendered messages, e.g. over SMTP.
+type Channel interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// ForRegistration addresses a notification to the attendee of reg, once for every channel they have
+// chosen. SMS is left out if the registration has no phone number.
+func ForRegistration(reg *model.Registration, typ Type, data Data) []Notification {
+	n := Notification{Type: typ, Data: data, RegistrationID: int64(reg.ID)}
+
+	var out []Notification
+	if reg.NotifyChannel != model.NotifySMS {
+		email := n
+		email.Channel, email.To = model.NotifyEmail, reg.Email
+		out = append(out, email)
+	}
+	if (reg.NotifyChannel == model.NotifySMS || reg.NotifyChannel == model.NotifyBoth) && reg.Phone != "" {
+		sms := n
+		sms.Channel, sms.To = model.NotifySMS, reg.Phone
+		out = append(out, sms)
+	}
+	return out
+}
+
+type notifier struct {
+	templates *Templates
+	email     Channel
+	sms       Channel
+	log       *zerolog.Logger
+}
+
+// New returns a Notifier that renders notifications with templates and sends them through the
+// email or the sms channel. sms may be nil if text messages are not sent.
+func New(templates *Templates, email, sms Channel, log *zerolog.Logger) Notifier {
+	return &notifier{templates: templates, email: email, sms: sms, log: log}
+}
+
+func (n *notifier) Notify(ctx context.Context, notification Notification) error {
+	channel := n.email
+	if notification.Channel == model.NotifySMS {
+		channel = n.sms
+	}
+	if channel == nil {
+		return fmt.Errorf("%w: %s", ErrNoChannel, notification.Channel)
+	}
+
+	msg, err := n.templates.Render(notification)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrRender, err)
+	}
+
+	if err := channel.Send(ctx, msg); err != nil {
+		n.log.Warn().Msgf("Ошибка при отправке уведомления пользователю %s: %v", notification.To, err)
+		return fmt.Errorf("send %s notification: %w", notification.Type, err)
+	}
+
+	n.log.Info().Msgf("📧 Уведомление успешно отправлено пользователю %s (тип: %s)", notification.To, notification.Type)
+	return nil
+}
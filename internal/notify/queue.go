@@ -0,0 +1,82 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"fifthOne/internal/model"
+	"fifthOne/internal/repo"
+	"fifthOne/pkg/token"
+)
+
+// Outbox returns the repo.NotificationBuilder that tells the attendee about every booking and status
+// change. The repository stores the notifications in the same transaction as the change, and the
+// Dispatcher in the worker renders and sends them, so callers never wait for a mail server and a
+// committed change is never left unannounced. publicURL is the base of the links in them; the links
+// are sealed with linkSecret, which the Dispatcher needs to open them.
+func Outbox(publicURL, linkSecret string) repo.NotificationBuilder {
+	return func(change repo.StatusChange) ([]*model.Notification, error) {
+		typ, data, ok := forChange(publicURL, change)
+		if !ok {
+			return nil, nil
+		}
+		if data.Link != "" {
+			sealed, err := token.Seal(linkSecret, data.Link)
+			if err != nil {
+				return nil, fmt.Errorf("failed to seal notification link: %w", err)
+			}
+			data.Link, data.SealedLink = "", sealed
+		}
+		return Queued(change.Registration, typ, data)
+	}
+}
+
+// forChange picks the notification about a status change; ok is false if there is none, e.g. for a check-in.
+func forChange(publicURL string, change repo.StatusChange) (typ Type, data Data, ok bool) {
+	reg, event := change.Registration, change.Event
+	data = Data{EventName: event.Name}
+
+	switch reg.Status {
+	case model.StatusPending:
+		data.TimeoutMinutes = event.PaymentTimeoutMinutes
+		data.Link = BookingLink(publicURL, int64(reg.EventID), reg.ConfirmationToken, event.IsPaid())
+		if change.From == model.StatusWaitlisted {
+			return TypePromoted, data, true
+		}
+		return TypePending, data, true
+	case model.StatusWaitlisted:
+		return TypeWaitlisted, data, true
+	case model.StatusConfirmed:
+		return TypeConfirmed, data, true
+	case model.StatusExpired:
+		return TypeExpired, data, true
+	case model.StatusCanceled:
+		if change.Actor == model.ActorUser {
+			return TypeCanceled, data, true
+		}
+		return TypeCanceledByOrganizer, data, true
+	case model.StatusRefunded:
+		return TypeRefunded, data, true
+	}
+	return "", Data{}, false
+}
+
+// Queued addresses a notification to the attendee of reg like ForRegistration does and encodes it
+// for the notifications table.
+func Queued(reg *model.Registration, typ Type, data Data) ([]*model.Notification, error) {
+	var out []*model.Notification
+	for _, n := range ForRegistration(reg, typ, data) {
+		payload, err := json.Marshal(n.Data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal notification data: %w", err)
+		}
+		out = append(out, &model.Notification{
+			RegistrationID: n.RegistrationID,
+			Type:           string(n.Type),
+			Channel:        n.Channel,
+			Recipient:      n.To,
+			Payload:        payload,
+		})
+	}
+	return out, nil
+}
@@ -0,0 +1,67 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"fifthOne/internal/model"
+	"fifthOne/internal/repo"
+)
+
+type recordingNotifier struct{ sent []Notification }
+
+func (r *recordingNotifier) Notify(_ context.Context, n Notification) error {
+	r.sent = append(r.sent, n)
+	return nil
+}
+
+func TestOutboxSealsLink(t *testing.T) {
+	const plainToken = "plain-confirmation-token"
+	change := repo.StatusChange{
+		Registration: &model.Registration{
+			ID:                7,
+			EventID:           3,
+			Email:             "a@example.com",
+			Status:            model.StatusPending,
+			ConfirmationToken: plainToken,
+		},
+		Event: &model.Event{ID: 3, Name: "Go Meetup", PaymentTimeoutMinutes: 15},
+	}
+
+	queued, err := Outbox("https://eventbooker.example", "link-secret")(change)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queued) != 1 {
+		t.Fatalf("got %d notifications, want 1", len(queued))
+	}
+	n := queued[0]
+	if strings.Contains(string(n.Payload), plainToken) {
+		t.Fatalf("payload holds the plain token: %s", n.Payload)
+	}
+	var stored map[string]any
+	if err := json.Unmarshal(n.Payload, &stored); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := stored["link"]; ok {
+		t.Errorf("payload has a plain link: %s", n.Payload)
+	}
+
+	// the dispatcher opens the link again right before sending
+	notifier := &recordingNotifier{}
+	d := &Dispatcher{notifier: notifier, unsubscribe: NewUnsubscribe("https://eventbooker.example", "s"), linkSecret: "link-secret"}
+	if err := d.send(context.Background(), n); err != nil {
+		t.Fatal(err)
+	}
+	want := ConfirmationLink("https://eventbooker.example", 3, plainToken)
+	if got := notifier.sent[0].Data.Link; got != want {
+		t.Errorf("sent link %q, want %q", got, want)
+	}
+
+	d.linkSecret = "other-secret"
+	if err := d.send(context.Background(), n); err == nil {
+		t.Error("link opened with the wrong secret")
+	}
+}
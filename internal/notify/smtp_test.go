@@ -0,0 +1,155 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func newFakeSMTP(t *testing.T) (*FakeSMTP, *SMTP) {
+	t.Helper()
+	log := zerolog.Nop()
+	server, err := ListenFakeSMTP("127.0.0.1:0", &log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = server.Close() })
+
+	host, port, err := net.SplitHostPort(server.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	portNum, _ := strconv.Atoi(port)
+	client, err := NewSMTP(SMTPConfig{Host: host, Port: portNum, From: "EventBooker <noreply@example.com>", TLS: TLSNone})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server, client
+}
+
+func TestNotifyThroughFakeSMTP(t *testing.T) {
+	server, client := newFakeSMTP(t)
+	log := zerolog.Nop()
+	notifier := New(loadTemplates(t), client, nil, &log)
+
+	err := notifier.Notify(context.Background(), Notification{
+		Type: TypePending,
+		To:   "attendee@example.com",
+		Data: Data{
+			EventName:      "Go Meetup",
+			TimeoutMinutes: 15,
+			Link:           "https://eventbooker.example/confirm?token=abc",
+			UnsubscribeURL: "https://eventbooker.example/v1/unsubscribe?token=xyz",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received := server.Messages()
+	if len(received) != 1 {
+		t.Fatalf("got %d messages, want 1", len(received))
+	}
+	got := received[0]
+	if got.From != "noreply@example.com" || len(got.To) != 1 || got.To[0] != "attendee@example.com" {
+		t.Errorf("envelope from %q to %v", got.From, got.To)
+	}
+	if !strings.Contains(got.Subject, "Вы начали регистрацию") {
+		t.Errorf("subject %q", got.Subject)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(string(got.Data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := msg.Header.Get("List-Unsubscribe"); h != "<https://eventbooker.example/v1/unsubscribe?token=xyz>" {
+		t.Errorf("List-Unsubscribe %q", h)
+	}
+	if h := msg.Header.Get("List-Unsubscribe-Post"); h != "List-Unsubscribe=One-Click" {
+		t.Errorf("List-Unsubscribe-Post %q", h)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("content type %q: %v", mediaType, err)
+	}
+	parts := map[string]string{}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := mr.NextRawPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if enc := part.Header.Get("Content-Transfer-Encoding"); enc != "quoted-printable" {
+			t.Errorf("part encoding %q", enc)
+		}
+		body, err := io.ReadAll(quotedprintable.NewReader(part))
+		if err != nil {
+			t.Fatal(err)
+		}
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		parts[partType] = string(body)
+	}
+
+	if text := parts["text/plain"]; !strings.Contains(text, "«Go Meetup»") || !strings.Contains(text, "https://eventbooker.example/confirm?token=abc") {
+		t.Errorf("text part:\n%s", text)
+	}
+	if html := parts["text/html"]; !strings.Contains(html, `href="https://eventbooker.example/confirm?token=abc"`) {
+		t.Errorf("html part:\n%s", html)
+	}
+}
+
+func TestSendPlainTextThroughFakeSMTP(t *testing.T) {
+	server, client := newFakeSMTP(t)
+
+	err := client.Send(context.Background(), Message{To: "attendee@example.com", Subject: "Проверка связи", Text: "Строка с кириллицей\n"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received := server.Messages()
+	if len(received) != 1 {
+		t.Fatalf("got %d messages, want 1", len(received))
+	}
+	if received[0].Subject != "Проверка связи" {
+		t.Errorf("subject %q", received[0].Subject)
+	}
+	msg, err := mail.ReadMessage(strings.NewReader(string(received[0].Data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct := msg.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("content type %q", ct)
+	}
+	if msg.Header.Get("List-Unsubscribe") != "" {
+		t.Error("List-Unsubscribe set without an unsubscribe URL")
+	}
+	body, err := io.ReadAll(quotedprintable.NewReader(msg.Body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(body), "Строка с кириллицей") {
+		t.Errorf("body:\n%s", body)
+	}
+}
+
+func TestNotifyWithoutChannel(t *testing.T) {
+	log := zerolog.Nop()
+	err := New(loadTemplates(t), nil, nil, &log).Notify(context.Background(), Notification{Type: TypePending, To: "a@example.com"})
+	if !errors.Is(err, ErrNoChannel) {
+		t.Fatalf("got %v, want ErrNoChannel", err)
+	}
+}
@@ -0,0 +1,128 @@
+package notify
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+
+	"fifthOne/internal/model"
+)
+
+// Templates holds the message templates of every notification type, loaded from a directory with
+// three files per type:
+//
+//	<type>.subject.tmpl  subject line (text/template)
+//	<type>.txt.tmpl      plain-text body (text/template)
+//	<type>.html.tmpl     HTML body (html/template), optional
+//	<type>.sms.tmpl      short text for SMS (text/template), optional; the plain-text body otherwise
+//
+// Bodies can use the "footer" template defined in _footer.txt.tmpl and _footer.html.tmpl.
+type Templates struct {
+	subject map[Type]*texttemplate.Template
+	text    map[Type]*texttemplate.Template
+	html    map[Type]*htmltemplate.Template
+	sms     map[Type]*texttemplate.Template
+}
+
+func LoadTemplates(dir string) (*Templates, error) {
+	t := &Templates{
+		subject: make(map[Type]*texttemplate.Template, len(Types)),
+		text:    make(map[Type]*texttemplate.Template, len(Types)),
+		html:    make(map[Type]*htmltemplate.Template, len(Types)),
+		sms:     make(map[Type]*texttemplate.Template, len(Types)),
+	}
+
+	textFooter := filepath.Join(dir, "_footer.txt.tmpl")
+	htmlFooter := filepath.Join(dir, "_footer.html.tmpl")
+
+	for _, typ := range Types {
+		subject, err := texttemplate.ParseFiles(filepath.Join(dir, string(typ)+".subject.tmpl"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s subject template: %w", typ, err)
+		}
+		t.subject[typ] = subject.Option("missingkey=error")
+
+		text, err := texttemplate.ParseFiles(filepath.Join(dir, string(typ)+".txt.tmpl"), textFooter)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s text template: %w", typ, err)
+		}
+		t.text[typ] = text.Option("missingkey=error")
+
+		smsFile := filepath.Join(dir, string(typ)+".sms.tmpl")
+		if _, err := os.Stat(smsFile); err == nil {
+			sms, err := texttemplate.ParseFiles(smsFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load %s sms template: %w", typ, err)
+			}
+			t.sms[typ] = sms.Option("missingkey=error")
+		}
+
+		htmlFile := filepath.Join(dir, string(typ)+".html.tmpl")
+		if _, err := os.Stat(htmlFile); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		html, err := htmltemplate.ParseFiles(htmlFile, htmlFooter)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s html template: %w", typ, err)
+		}
+		t.html[typ] = html.Option("missingkey=error")
+	}
+
+	return t, nil
+}
+
+func (t *Templates) Render(n Notification) (Message, error) {
+	subjectTmpl, ok := t.subject[n.Type]
+	if !ok {
+		return Message{}, fmt.Errorf("unknown notification type %q", n.Type)
+	}
+
+	if n.Channel == model.NotifySMS {
+		return t.renderSMS(n)
+	}
+
+	var subject, text bytes.Buffer
+	if err := subjectTmpl.Execute(&subject, n.Data); err != nil {
+		return Message{}, fmt.Errorf("failed to render %s subject: %w", n.Type, err)
+	}
+	if err := t.text[n.Type].Execute(&text, n.Data); err != nil {
+		return Message{}, fmt.Errorf("failed to render %s text: %w", n.Type, err)
+	}
+
+	msg := Message{
+		To: n.To,
+		// a subject is a single header line
+		Subject:        strings.Join(strings.Fields(subject.String()), " "),
+		Text:           strings.TrimSpace(text.String()) + "\n",
+		UnsubscribeURL: n.Data.UnsubscribeURL,
+	}
+
+	if htmlTmpl, ok := t.html[n.Type]; ok {
+		var html bytes.Buffer
+		if err := htmlTmpl.Execute(&html, n.Data); err != nil {
+			return Message{}, fmt.Errorf("failed to render %s html: %w", n.Type, err)
+		}
+		msg.HTML = html.String()
+	}
+
+	return msg, nil
+}
+
+// renderSMS renders the text of a text message; it has no subject or HTML.
+func (t *Templates) renderSMS(n Notification) (Message, error) {
+	tmpl, ok := t.sms[n.Type]
+	if !ok {
+		tmpl = t.text[n.Type]
+	}
+
+	var text bytes.Buffer
+	if err := tmpl.Execute(&text, n.Data); err != nil {
+		return Message{}, fmt.Errorf("failed to render %s sms: %w", n.Type, err)
+	}
+	return Message{To: n.To, Text: strings.TrimSpace(text.String())}, nil
+}
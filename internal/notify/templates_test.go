@@ -0,0 +1,102 @@
+package notify
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"fifthOne/internal/model"
+)
+
+const templatesDir = "../../templates/notifications"
+
+func loadTemplates(t *testing.T) *Templates {
+	t.Helper()
+	templates, err := LoadTemplates(templatesDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return templates
+}
+
+func TestRenderEveryType(t *testing.T) {
+	templates := loadTemplates(t)
+	start := time.Date(2026, 6, 1, 18, 0, 0, 0, time.UTC)
+	data := Data{
+		EventName:      "Go Meetup",
+		TimeoutMinutes: 15,
+		Link:           "https://eventbooker.example/confirm",
+		StartTime:      &start,
+		Location:       "Москва",
+		UnsubscribeURL: "https://eventbooker.example/v1/unsubscribe?token=abc",
+	}
+
+	for _, typ := range Types {
+		t.Run(string(typ), func(t *testing.T) {
+			msg, err := templates.Render(Notification{Type: typ, To: "a@example.com", Data: data})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if msg.To != "a@example.com" || msg.UnsubscribeURL != data.UnsubscribeURL {
+				t.Errorf("got To %q, UnsubscribeURL %q", msg.To, msg.UnsubscribeURL)
+			}
+			if msg.Subject == "" || strings.ContainsAny(msg.Subject, "\r\n") {
+				t.Errorf("subject %q is not a single non-empty line", msg.Subject)
+			}
+			for _, body := range []string{msg.Text, msg.HTML} {
+				if !strings.Contains(body, data.EventName) {
+					t.Errorf("body does not name the event:\n%s", body)
+				}
+				if !strings.Contains(body, data.UnsubscribeURL) {
+					t.Errorf("body has no unsubscribe link:\n%s", body)
+				}
+			}
+
+			sms, err := templates.Render(Notification{Type: typ, Channel: model.NotifySMS, To: "+79991234567", Data: data})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sms.Text == "" || sms.Subject != "" || sms.HTML != "" {
+				t.Errorf("sms = %+v, want text only", sms)
+			}
+		})
+	}
+}
+
+func TestRenderLink(t *testing.T) {
+	templates := loadTemplates(t)
+
+	for _, typ := range []Type{TypePending, TypePromoted} {
+		with, err := templates.Render(Notification{Type: typ, Data: Data{EventName: "Go Meetup", Link: "https://eventbooker.example/confirm"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(with.Text, "https://eventbooker.example/confirm") || !strings.Contains(with.HTML, `href="https://eventbooker.example/confirm"`) {
+			t.Errorf("%s: link missing:\n%s\n%s", typ, with.Text, with.HTML)
+		}
+
+		without, err := templates.Render(Notification{Type: typ, Data: Data{EventName: "Go Meetup"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(without.HTML, "href") || strings.Contains(without.Text, "Отписаться") {
+			t.Errorf("%s: link or footer rendered without data:\n%s\n%s", typ, without.Text, without.HTML)
+		}
+	}
+}
+
+func TestRenderEscapesHTML(t *testing.T) {
+	msg, err := loadTemplates(t).Render(Notification{Type: TypeConfirmed, Data: Data{EventName: "<script>x</script>"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(msg.HTML, "<script>") {
+		t.Errorf("event name not escaped in HTML:\n%s", msg.HTML)
+	}
+}
+
+func TestRenderUnknownType(t *testing.T) {
+	if _, err := loadTemplates(t).Render(Notification{Type: "bogus"}); err == nil {
+		t.Fatal("expected an error for an unknown type")
+	}
+}
@@ -0,0 +1,49 @@
+package notify
+
+import (
+	"net/url"
+	"strings"
+
+	"fifthOne/internal/model"
+	"fifthOne/pkg/token"
+)
+
+// UnsubscribeAll in an unsubscribe link opts out of every optional category. Transactional
+// messages carry it, as they cannot be opted out of themselves.
+const UnsubscribeAll = "all"
+
+// Unsubscribe builds and checks the signed one-click unsubscribe links put into every message.
+// The signature covers the contact and the category, so a link cannot be altered to unsubscribe
+// someone else.
+type Unsubscribe struct {
+	publicURL string
+	secret    string
+}
+
+func NewUnsubscribe(publicURL, secret string) *Unsubscribe {
+	return &Unsubscribe{publicURL: strings.TrimRight(publicURL, "/"), secret: secret}
+}
+
+// Link is the unsubscribe URL for a message of category sent to contact.
+func (u *Unsubscribe) Link(contact string, category model.NotificationCategory) string {
+	target := string(category)
+	if !category.Optional() {
+		target = UnsubscribeAll
+	}
+	contact = model.NormalizeContact(contact)
+
+	q := url.Values{}
+	q.Set("contact", contact)
+	q.Set("category", target)
+	q.Set("sig", token.Sign(u.secret, unsubscribeMessage(contact, target)))
+	return u.publicURL + "/v1/unsubscribe?" + q.Encode()
+}
+
+// Valid reports whether signature was issued by Link for contact and category.
+func (u *Unsubscribe) Valid(contact, category, signature string) bool {
+	return token.Verify(u.secret, unsubscribeMessage(model.NormalizeContact(contact), category), signature)
+}
+
+func unsubscribeMessage(contact, category string) string {
+	return contact + "\n" + category
+}
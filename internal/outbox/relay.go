@@ -0,0 +1,149 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"fifthOne/internal/dto"
+	"fifthOne/internal/model"
+	"fifthOne/internal/repo"
+	"fifthOne/internal/scheduler"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	defaultInterval  = time.Second
+	defaultBatchSize = 50
+	// claimLease keeps a claimed message away from other relays while it is being delivered.
+	claimLease = 30 * time.Second
+	maxBackoff = time.Minute
+)
+
+// Relay hands outbox messages to the expiry scheduler and marks them sent once the scheduler has
+// accepted them (for RabbitMQ, once the broker has confirmed the message). Delivery is
+// at-least-once: a relay that dies before MarkOutboxSent delivers the message again after the
+// lease, so the expiry handler must be idempotent. Several relays may run against the same database.
+type Relay struct {
+	repo     repo.Repository
+	sched    scheduler.Scheduler
+	log      *zerolog.Logger
+	interval time.Duration
+	batch    int
+
+	done   chan struct{}
+	cancel context.CancelFunc
+}
+
+func NewRelay(repo repo.Repository, sched scheduler.Scheduler, log *zerolog.Logger) *Relay {
+	return &Relay{
+		repo:     repo,
+		sched:    sched,
+		log:      log,
+		interval: defaultInterval,
+		batch:    defaultBatchSize,
+		done:     make(chan struct{}),
+	}
+}
+
+func (r *Relay) Start(ctx context.Context) {
+	cctx, cancel := context.WithCancel(ctx)
+	r.cancel = cancel
+
+	r.log.Info().Msg("📤 Outbox relay started")
+
+	go func() {
+		defer close(r.done)
+
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		for {
+			// a full batch means more messages are probably waiting
+			if r.runOnce(cctx) == r.batch && cctx.Err() == nil {
+				continue
+			}
+
+			select {
+			case <-cctx.Done():
+				r.log.Info().Msg("🛑 Outbox relay stopped by context")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (r *Relay) Stop() {
+	if r.cancel != nil {
+		r.cancel()
+		<-r.done
+	}
+}
+
+// runOnce delivers one batch and returns how many messages were claimed.
+func (r *Relay) runOnce(ctx context.Context) int {
+	messages, err := r.repo.ClaimOutbox(ctx, r.batch, claimLease)
+	if err != nil {
+		if ctx.Err() == nil {
+			r.log.Error().Err(err).Msg("Failed to claim outbox messages")
+		}
+		return 0
+	}
+
+	for i := range messages {
+		r.publish(ctx, &messages[i])
+	}
+	return len(messages)
+}
+
+func (r *Relay) publish(ctx context.Context, m *model.OutboxMessage) {
+	log := r.log.With().
+		Int64("outbox_id", m.ID).
+		Str("topic", m.Topic).
+		Int("attempt", m.Attempts).
+		Logger()
+
+	if err := r.deliver(ctx, m); err != nil {
+		retryAt := time.Now().Add(backoff(m.Attempts))
+		log.Warn().Err(err).Time("retry_at", retryAt).Msg("Failed to deliver outbox message")
+		if err := r.repo.FailOutbox(ctx, m.ID, err.Error(), retryAt); err != nil {
+			log.Error().Err(err).Msg("Failed to record outbox failure")
+		}
+		return
+	}
+
+	if err := r.repo.MarkOutboxSent(ctx, m.ID); err != nil {
+		log.Error().Err(err).Msg("Outbox message delivered but not marked sent, it will be delivered again")
+		return
+	}
+	log.Debug().Msg("Outbox message delivered")
+}
+
+func (r *Relay) deliver(ctx context.Context, m *model.OutboxMessage) error {
+	switch m.Topic {
+	case model.OutboxTopicRegistrationExpiry:
+		var msg dto.RegistrationOperateMessage
+		if err := json.Unmarshal(m.Payload, &msg); err != nil {
+			return fmt.Errorf("invalid expiry message: %w", err)
+		}
+		return r.sched.ScheduleExpiry(ctx, scheduler.Expiry{
+			RegistrationID: msg.RegistrationID,
+			Generation:     msg.Generation,
+			At:             m.DeliverAt,
+		})
+	default:
+		return fmt.Errorf("unknown outbox topic %q", m.Topic)
+	}
+}
+
+// backoff doubles from one second per attempt, capped at maxBackoff.
+func backoff(attempt int) time.Duration {
+	d := time.Second
+	for i := 1; i < attempt && d < maxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxBackoff)
+}
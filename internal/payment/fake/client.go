@@ -0,0 +1,154 @@
+package fake
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"fifthOne/internal/payment"
+)
+
+// Client is the payment.Provider talking to a fake Server over HTTP, the same way an adapter
+// for a real gateway would. The worker and the API can therefore share one gateway instance.
+type Client struct {
+	baseURL       string
+	webhookSecret string
+	http          *http.Client
+}
+
+func NewClient(baseURL, webhookSecret string) *Client {
+	return &Client{
+		baseURL:       strings.TrimRight(baseURL, "/"),
+		webhookSecret: webhookSecret,
+		http:          &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (c *Client) Name() string {
+	return "fake"
+}
+
+func (c *Client) CreateIntent(ctx context.Context, req payment.CreateIntentRequest) (*payment.Intent, error) {
+	var resp intentJSON
+	err := c.do(ctx, http.MethodPost, "/api/intents", createIntentJSON{
+		Reference:   req.Reference,
+		Amount:      req.Amount,
+		Currency:    req.Currency,
+		Description: req.Description,
+		ReturnURL:   req.ReturnURL,
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp.toIntent(), nil
+}
+
+func (c *Client) Capture(ctx context.Context, intentID string) (*payment.Intent, error) {
+	var resp intentJSON
+	if err := c.do(ctx, http.MethodPost, "/api/intents/"+intentID+"/capture", nil, &resp); err != nil {
+		if err == errConflict {
+			return nil, payment.ErrNotAuthorized
+		}
+		return nil, err
+	}
+	return resp.toIntent(), nil
+}
+
+func (c *Client) Refund(ctx context.Context, intentID string, amount int64, idempotencyKey string) (*payment.Refund, error) {
+	var resp refundJSON
+	headers := map[string]string{idempotencyHeader: idempotencyKey}
+	if err := c.doWithHeaders(ctx, http.MethodPost, "/api/intents/"+intentID+"/refunds", headers, map[string]int64{"amount": amount}, &resp); err != nil {
+		if err == errConflict {
+			return nil, payment.ErrRefundNotPossible
+		}
+		return nil, err
+	}
+	return &payment.Refund{ID: resp.ID, IntentID: resp.IntentID, Amount: resp.Amount, Status: resp.Status}, nil
+}
+
+// VerifyWebhook checks the hex HMAC-SHA256 of the raw payload against signature.
+func (c *Client) VerifyWebhook(payload []byte, signature string) (*payment.WebhookEvent, error) {
+	if c.webhookSecret == "" || !hmac.Equal([]byte(Sign(c.webhookSecret, payload)), []byte(signature)) {
+		return nil, payment.ErrInvalidWebhook
+	}
+
+	var event payment.WebhookEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("invalid webhook payload: %w", err)
+	}
+	return &event, nil
+}
+
+// Sign returns the signature the fake gateway attaches to webhook payloads.
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (in intentJSON) toIntent() *payment.Intent {
+	return &payment.Intent{
+		ID:          in.ID,
+		Reference:   in.Reference,
+		Amount:      in.Amount,
+		Currency:    in.Currency,
+		Status:      in.Status,
+		CheckoutURL: in.CheckoutURL,
+	}
+}
+
+var errConflict = errors.New("fake gateway: conflict")
+
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	return c.doWithHeaders(ctx, method, path, nil, body, out)
+}
+
+func (c *Client) doWithHeaders(ctx context.Context, method, path string, headers map[string]string, body, out any) error {
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, &buf)
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		if v != "" {
+			req.Header.Set(k, v)
+		}
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("fake gateway request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return payment.ErrIntentNotFound
+	case resp.StatusCode == http.StatusConflict:
+		return errConflict
+	case resp.StatusCode >= 300:
+		var e errorJSON
+		_ = json.NewDecoder(resp.Body).Decode(&e)
+		return fmt.Errorf("fake gateway returned %d: %s", resp.StatusCode, e.Error)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
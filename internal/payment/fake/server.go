@@ -0,0 +1,387 @@
+package fake
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"fifthOne/internal/payment"
+
+	"github.com/rs/zerolog"
+)
+
+const idempotencyHeader = "Idempotency-Key"
+
+// MountPath is where the API server exposes the gateway when it is run in-process.
+const MountPath = "/fake-pay"
+
+// Server is an in-memory payment gateway with a checkout page, so the paid booking flow
+// can be exercised without a real provider. State is lost on restart.
+type Server struct {
+	baseURL       string
+	webhookURL    string
+	webhookSecret string
+	log           *zerolog.Logger
+	http          *http.Client
+
+	mu      sync.Mutex
+	seq     int
+	intents map[string]*intentState
+	refunds map[string]*refundJSON
+	// refundKeys maps idempotency keys to the refund they created.
+	refundKeys map[string]string
+}
+
+type intentState struct {
+	intentJSON
+	ReturnURL   string
+	Description string
+	Refunded    int64
+}
+
+type intentJSON struct {
+	ID          string         `json:"id"`
+	Reference   string         `json:"reference"`
+	Amount      int64          `json:"amount"`
+	Currency    string         `json:"currency"`
+	Status      payment.Status `json:"status"`
+	CheckoutURL string         `json:"checkout_url"`
+}
+
+type createIntentJSON struct {
+	Reference   string `json:"reference"`
+	Amount      int64  `json:"amount"`
+	Currency    string `json:"currency"`
+	Description string `json:"description"`
+	ReturnURL   string `json:"return_url"`
+}
+
+type refundJSON struct {
+	ID       string         `json:"id"`
+	IntentID string         `json:"intent_id"`
+	Amount   int64          `json:"amount"`
+	Status   payment.Status `json:"status"`
+}
+
+type errorJSON struct {
+	Error string `json:"error"`
+}
+
+// NewServer creates the gateway. baseURL is the address the gateway is reachable at from the
+// payer's browser; checkout URLs handed out to clients are built from it. Every state change is
+// reported to webhookURL, signed with webhookSecret; an empty webhookURL disables webhooks.
+func NewServer(baseURL, webhookURL, webhookSecret string, log *zerolog.Logger) *Server {
+	return &Server{
+		baseURL:       strings.TrimRight(baseURL, "/"),
+		webhookURL:    webhookURL,
+		webhookSecret: webhookSecret,
+		log:           log,
+		http:          &http.Client{Timeout: 10 * time.Second},
+		intents:       make(map[string]*intentState),
+		refunds:       make(map[string]*refundJSON),
+		refundKeys:    make(map[string]string),
+	}
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/intents", s.createIntent)
+	mux.HandleFunc("GET /api/intents/{id}", s.getIntent)
+	mux.HandleFunc("POST /api/intents/{id}/capture", s.capture)
+	mux.HandleFunc("POST /api/intents/{id}/refunds", s.refund)
+	mux.HandleFunc("GET /checkout/{id}", s.checkoutPage)
+	mux.HandleFunc("POST /checkout/{id}", s.checkoutSubmit)
+	return mux
+}
+
+func (s *Server) nextID(prefix string) string {
+	s.seq++
+	return fmt.Sprintf("%s_%06d", prefix, s.seq)
+}
+
+func (s *Server) createIntent(w http.ResponseWriter, r *http.Request) {
+	var req createIntentJSON
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorJSON{Error: "invalid JSON"})
+		return
+	}
+	if req.Amount <= 0 || req.Currency == "" {
+		writeJSON(w, http.StatusBadRequest, errorJSON{Error: "amount and currency are required"})
+		return
+	}
+
+	s.mu.Lock()
+	id := s.nextID("pi")
+	in := &intentState{
+		intentJSON: intentJSON{
+			ID:          id,
+			Reference:   req.Reference,
+			Amount:      req.Amount,
+			Currency:    strings.ToUpper(req.Currency),
+			Status:      payment.StatusRequiresPayment,
+			CheckoutURL: s.baseURL + "/checkout/" + id,
+		},
+		ReturnURL:   req.ReturnURL,
+		Description: req.Description,
+	}
+	s.intents[id] = in
+	resp := in.intentJSON
+	s.mu.Unlock()
+
+	s.log.Info().Str("intent_id", id).Str("reference", req.Reference).Int64("amount", req.Amount).Msg("fake gateway: intent created")
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+func (s *Server) getIntent(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	in, ok := s.intents[r.PathValue("id")]
+	var resp intentJSON
+	if ok {
+		resp = in.intentJSON
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		writeJSON(w, http.StatusNotFound, errorJSON{Error: "intent not found"})
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) capture(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	in, ok := s.intents[r.PathValue("id")]
+	if !ok {
+		s.mu.Unlock()
+		writeJSON(w, http.StatusNotFound, errorJSON{Error: "intent not found"})
+		return
+	}
+	var event *payment.WebhookEvent
+	switch in.Status {
+	case payment.StatusAuthorized:
+		in.Status = payment.StatusSucceeded
+		event = s.newEvent(payment.EventPaymentSucceeded, in.ID, in.Amount)
+	case payment.StatusSucceeded:
+	default:
+		status := in.Status
+		s.mu.Unlock()
+		writeJSON(w, http.StatusConflict, errorJSON{Error: "intent is " + string(status)})
+		return
+	}
+	resp := in.intentJSON
+	s.mu.Unlock()
+
+	s.log.Info().Str("intent_id", resp.ID).Msg("fake gateway: payment captured")
+	s.sendWebhook(event)
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) refund(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Amount int64 `json:"amount"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorJSON{Error: "invalid JSON"})
+		return
+	}
+
+	key := r.Header.Get(idempotencyHeader)
+
+	s.mu.Lock()
+	if id, ok := s.refundKeys[key]; ok && key != "" {
+		resp := *s.refunds[id]
+		s.mu.Unlock()
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+	in, ok := s.intents[r.PathValue("id")]
+	if !ok {
+		s.mu.Unlock()
+		writeJSON(w, http.StatusNotFound, errorJSON{Error: "intent not found"})
+		return
+	}
+	if in.Status != payment.StatusSucceeded || req.Amount <= 0 || in.Refunded+req.Amount > in.Amount {
+		s.mu.Unlock()
+		writeJSON(w, http.StatusConflict, errorJSON{Error: "refund is not possible"})
+		return
+	}
+	in.Refunded += req.Amount
+	if in.Refunded == in.Amount {
+		in.Status = payment.StatusRefunded
+	}
+	ref := &refundJSON{ID: s.nextID("re"), IntentID: in.ID, Amount: req.Amount, Status: payment.StatusSucceeded}
+	s.refunds[ref.ID] = ref
+	if key != "" {
+		s.refundKeys[key] = ref.ID
+	}
+	resp := *ref
+	event := s.newEvent(payment.EventRefundSucceeded, in.ID, ref.Amount)
+	event.RefundID = ref.ID
+	s.mu.Unlock()
+
+	s.log.Info().Str("intent_id", resp.IntentID).Str("refund_id", resp.ID).Int64("amount", resp.Amount).Msg("fake gateway: refund issued")
+	s.sendWebhook(event)
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+var checkoutTmpl = template.Must(template.New("checkout").Parse(`<!DOCTYPE html>
+<html lang="ru">
+<head>
+    <meta charset="UTF-8">
+    <title>Тестовая оплата</title>
+    <style>
+        body { font-family: Arial, sans-serif; max-width: 480px; margin: 40px auto; }
+        .card { border: 1px solid #ccc; padding: 20px; border-radius: 8px; }
+        button { margin-top: 10px; margin-right: 10px; padding: 8px 16px; }
+    </style>
+</head>
+<body>
+<div class="card">
+    <h2>Тестовый платёжный шлюз</h2>
+    <p>{{.Description}}</p>
+    <p>Сумма: <b>{{.Amount}}</b></p>
+    <p>Платёж: {{.ID}} ({{.Status}})</p>
+    {{if eq .Status "requires_payment"}}
+    <form method="post">
+        <button name="action" value="pay">Оплатить</button>
+        <button name="action" value="decline">Отклонить</button>
+    </form>
+    {{else if .ReturnURL}}
+    <p><a href="{{.ReturnURL}}">Вернуться на сайт</a></p>
+    {{end}}
+</div>
+</body>
+</html>
+`))
+
+type checkoutView struct {
+	ID          string
+	Description string
+	Amount      string
+	Status      payment.Status
+	ReturnURL   string
+}
+
+func (s *Server) checkoutPage(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	in, ok := s.intents[r.PathValue("id")]
+	var view checkoutView
+	if ok {
+		view = checkoutView{
+			ID:          in.ID,
+			Description: in.Description,
+			Amount:      formatAmount(in.Amount, in.Currency),
+			Status:      in.Status,
+			ReturnURL:   in.ReturnURL,
+		}
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := checkoutTmpl.Execute(w, view); err != nil {
+		s.log.Error().Err(err).Msg("fake gateway: failed to render checkout page")
+	}
+}
+
+// checkoutSubmit emulates the payer's decision. Paying only authorizes the intent; the merchant
+// still has to capture it.
+func (s *Server) checkoutSubmit(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	s.mu.Lock()
+	in, ok := s.intents[id]
+	if !ok {
+		s.mu.Unlock()
+		http.NotFound(w, r)
+		return
+	}
+	var event *payment.WebhookEvent
+	if in.Status == payment.StatusRequiresPayment {
+		if r.FormValue("action") == "pay" {
+			in.Status = payment.StatusAuthorized
+			event = s.newEvent(payment.EventPaymentAuthorized, in.ID, in.Amount)
+		} else {
+			in.Status = payment.StatusFailed
+			event = s.newEvent(payment.EventPaymentFailed, in.ID, in.Amount)
+		}
+	}
+	status, returnURL := in.Status, in.ReturnURL
+	s.mu.Unlock()
+
+	s.log.Info().Str("intent_id", id).Str("status", string(status)).Msg("fake gateway: checkout completed")
+	s.sendWebhook(event)
+
+	if returnURL == "" || status != payment.StatusAuthorized {
+		http.Redirect(w, r, s.baseURL+"/checkout/"+id, http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, returnURL, http.StatusSeeOther)
+}
+
+// newEvent must be called with s.mu held.
+func (s *Server) newEvent(eventType, intentID string, amount int64) *payment.WebhookEvent {
+	return &payment.WebhookEvent{
+		ID:       s.nextID("evt"),
+		Type:     eventType,
+		IntentID: intentID,
+		Amount:   amount,
+	}
+}
+
+// sendWebhook delivers the event in the background, retrying with backoff until the receiver
+// answers 2xx, like real gateways do. Receivers must therefore deduplicate by event ID.
+func (s *Server) sendWebhook(event *payment.WebhookEvent) {
+	if event == nil || s.webhookURL == "" {
+		return
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		s.log.Error().Err(err).Msg("fake gateway: failed to encode webhook")
+		return
+	}
+
+	go func() {
+		delay := time.Second
+		for attempt := 1; attempt <= 5; attempt++ {
+			req, err := http.NewRequest(http.MethodPost, s.webhookURL, bytes.NewReader(payload))
+			if err != nil {
+				s.log.Error().Err(err).Msg("fake gateway: failed to build webhook request")
+				return
+			}
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set(payment.SignatureHeader, Sign(s.webhookSecret, payload))
+
+			resp, err := s.http.Do(req)
+			if err == nil {
+				resp.Body.Close()
+				if resp.StatusCode < 300 {
+					s.log.Info().Str("event_id", event.ID).Str("type", event.Type).Msg("fake gateway: webhook delivered")
+					return
+				}
+				err = fmt.Errorf("receiver answered %d", resp.StatusCode)
+			}
+			s.log.Warn().Err(err).Str("event_id", event.ID).Int("attempt", attempt).Msg("fake gateway: webhook delivery failed")
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}()
+}
+
+func formatAmount(amount int64, currency string) string {
+	return fmt.Sprintf("%d.%02d %s", amount/100, amount%100, currency)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,80 @@
+package payment
+
+import (
+	"context"
+	"errors"
+)
+
+type Status string
+
+const (
+	StatusRequiresPayment Status = "requires_payment"
+	StatusAuthorized      Status = "authorized"
+	StatusSucceeded       Status = "succeeded"
+	StatusFailed          Status = "failed"
+	StatusRefunded        Status = "refunded"
+)
+
+// SignatureHeader carries the hex HMAC-SHA256 of the webhook body.
+const SignatureHeader = "X-Payment-Signature"
+
+// Webhook event types.
+const (
+	EventPaymentAuthorized = "payment.authorized"
+	EventPaymentSucceeded  = "payment.succeeded"
+	EventPaymentFailed     = "payment.failed"
+	EventRefundSucceeded   = "refund.succeeded"
+)
+
+var (
+	ErrIntentNotFound    = errors.New("payment intent not found")
+	ErrNotAuthorized     = errors.New("payment has not been authorized")
+	ErrInvalidWebhook    = errors.New("invalid webhook signature")
+	ErrRefundNotPossible = errors.New("refund is not possible for this payment")
+)
+
+type CreateIntentRequest struct {
+	// Reference ties the intent to our side, e.g. "registration:42".
+	Reference   string
+	Amount      int64
+	Currency    string
+	Description string
+	// ReturnURL is where the checkout page sends the payer once they are done.
+	ReturnURL string
+}
+
+type Intent struct {
+	ID          string
+	Reference   string
+	Amount      int64
+	Currency    string
+	Status      Status
+	CheckoutURL string
+}
+
+type Refund struct {
+	ID       string
+	IntentID string
+	Amount   int64
+	Status   Status
+}
+
+type WebhookEvent struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	IntentID string `json:"intent_id"`
+	RefundID string `json:"refund_id,omitempty"`
+	Amount   int64  `json:"amount"`
+}
+
+// Provider is a payment gateway. Amounts are in minor currency units.
+type Provider interface {
+	Name() string
+	CreateIntent(ctx context.Context, req CreateIntentRequest) (*Intent, error)
+	// Capture collects an authorized payment. It is idempotent for already captured intents.
+	Capture(ctx context.Context, intentID string) (*Intent, error)
+	// Refund returns amount of a captured payment. Retries with the same idempotencyKey
+	// return the original refund instead of paying out twice.
+	Refund(ctx context.Context, intentID string, amount int64, idempotencyKey string) (*Refund, error)
+	VerifyWebhook(payload []byte, signature string) (*WebhookEvent, error)
+}
@@ -1,47 +1,190 @@
 package rabbit
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"math"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	amqp "github.com/rabbitmq/amqp091-go"
 	"github.com/wb-go/wbf/zlog"
 )
 
+const (
+	minReconnectDelay = time.Second
+	maxReconnectDelay = 30 * time.Second
+
+	defaultMaxAttempts = 5
+	defaultWorkers     = 4
+	defaultRetryDelay  = 5 * time.Second
+	maxRetryDelay      = 10 * time.Minute
+
+	// MaxDelay is the longest x-delay the delayed-message exchange accepts: the header is a signed
+	// 32-bit number of milliseconds, about 24.8 days. Longer delays are cut to MaxDelay.
+	MaxDelay = time.Duration(math.MaxInt32) * time.Millisecond
+
+	// retryCountHeader counts how many times a message has already failed.
+	retryCountHeader = "x-retry-count"
+	lastErrorHeader  = "x-last-error"
+	failedAtHeader   = "x-failed-at"
+)
+
+var (
+	ErrPublishNacked = errors.New("message was not confirmed by the broker")
+	ErrNotConnected  = errors.New("not connected to RabbitMQ")
+)
+
+// Options tunes message handling. Zero values fall back to the defaults.
+type Options struct {
+	// MaxAttempts is how many times a message is handled before it is dead-lettered.
+	MaxAttempts int
+	// RetryDelay is the delay before the first retry; it doubles with every further attempt.
+	RetryDelay time.Duration
+	// Workers is how many deliveries each consumer handles concurrently.
+	Workers int
+	// Prefetch is the basic.qos limit of unacked deliveries on the consume channel; it defaults
+	// to twice Workers so that every worker has its next message ready.
+	Prefetch int
+}
+
+func (o Options) withDefaults() Options {
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = defaultMaxAttempts
+	}
+	if o.RetryDelay <= 0 {
+		o.RetryDelay = defaultRetryDelay
+	}
+	if o.Workers <= 0 {
+		o.Workers = defaultWorkers
+	}
+	if o.Prefetch <= 0 {
+		o.Prefetch = 2 * o.Workers
+	}
+	return o
+}
+
+// Client keeps a connection to RabbitMQ alive. When the connection or one of its channels is
+// closed it reconnects with backoff, declares the exchange, queue and binding again and
+// resubscribes every handler registered with Consume.
+//
+// A message whose handler fails is acked and published again through the delayed exchange with
+// an incremented x-retry-count header and an exponentially growing delay. After
+// Options.MaxAttempts failures, or on a Permanent error, it is moved to the dead-letter queue.
 type Client struct {
-	conn     *amqp.Connection
-	channel  *amqp.Channel
+	url      string
 	exchange string
 	queue    string
+	opts     Options
+
+	mu      sync.RWMutex
+	conn    *amqp.Connection
+	channel *amqp.Channel
+	// publishChannel is in publisher-confirm mode and is only used for publishing.
+	publishChannel *amqp.Channel
+	consumers      []*consumer
+
+	healthy   atomic.Bool
+	done      chan struct{}
+	closeOnce sync.Once
 }
 
 type Rabbiter interface {
-	NewRabbit(url, exchange, queue string) (*Client, error)
+	NewRabbit(url, exchange, queue string, opts Options) (*Client, error)
 	Close()
+	Healthy() bool
 	Publish(message []byte, delaySeconds int) error
-	Consume(handler func([]byte) error) error
+	PublishConfirmed(ctx context.Context, message []byte, delay time.Duration) error
+	Consume(ctx context.Context, handler func([]byte) error) error
 }
 
-func NewRabbit(url, exchange, queue string) (*Client, error) {
-	conn, err := amqp.Dial(url)
+func NewRabbit(url, exchange, queue string, opts Options) (*Client, error) {
+	client := &Client{
+		url:      url,
+		exchange: exchange,
+		queue:    queue,
+		opts:     opts.withDefaults(),
+		done:     make(chan struct{}),
+	}
+
+	if err := client.connect(); err != nil {
+		return nil, err
+	}
+
+	zlog.Logger.Info().Msgf("RabbitMQ initialized (exchange=%s, queue=%s)", exchange, queue)
+
+	go client.watch()
+	return client, nil
+}
+
+// connect dials the broker, declares the topology and resubscribes registered consumers.
+func (c *Client) connect() error {
+	conn, err := amqp.Dial(c.url)
 	if err != nil {
 		zlog.Logger.Error().Err(err).Msg("failed to connect to RabbitMQ")
-		return nil, err
+		return err
 	}
 
 	ch, err := conn.Channel()
 	if err != nil {
 		_ = conn.Close()
 		zlog.Logger.Error().Err(err).Msg("failed to open RabbitMQ channel")
-		return nil, err
+		return err
 	}
 
-	client := &Client{
-		conn:     conn,
-		channel:  ch,
-		exchange: exchange,
-		queue:    queue,
+	publishCh, err := conn.Channel()
+	if err != nil {
+		_ = conn.Close()
+		zlog.Logger.Error().Err(err).Msg("failed to open RabbitMQ publish channel")
+		return err
+	}
+	if err := publishCh.Confirm(false); err != nil {
+		_ = conn.Close()
+		zlog.Logger.Error().Err(err).Msg("failed to enable publisher confirms")
+		return err
+	}
+
+	if err := declareTopology(ch, c.exchange, c.queue, c.deadLetterExchange(), c.deadLetterQueue()); err != nil {
+		_ = conn.Close()
+		return err
 	}
 
+	if err := ch.Qos(c.opts.Prefetch, 0, false); err != nil {
+		_ = conn.Close()
+		zlog.Logger.Error().Err(err).Msg("failed to set RabbitMQ prefetch")
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	select {
+	case <-c.done:
+		// Close was called while we were dialing
+		_ = conn.Close()
+		return nil
+	default:
+	}
+
+	for _, cons := range c.consumers {
+		if err := c.subscribe(ch, cons); err != nil {
+			_ = conn.Close()
+			return err
+		}
+	}
+
+	c.conn = conn
+	c.channel = ch
+	c.publishChannel = publishCh
+	c.healthy.Store(true)
+	return nil
+}
+
+func declareTopology(ch *amqp.Channel, exchange, queue, dlx, dlq string) error {
 	args := amqp.Table{"x-delayed-type": "direct"}
 	if err := ch.ExchangeDeclare(
 		exchange,
@@ -53,7 +196,7 @@ func NewRabbit(url, exchange, queue string) (*Client, error) {
 		args,
 	); err != nil {
 		zlog.Logger.Error().Err(err).Msg("failed to declare exchange")
-		return nil, err
+		return err
 	}
 
 	if _, err := ch.QueueDeclare(
@@ -65,7 +208,7 @@ func NewRabbit(url, exchange, queue string) (*Client, error) {
 		nil,
 	); err != nil {
 		zlog.Logger.Error().Err(err).Msg("failed to declare queue")
-		return nil, err
+		return err
 	}
 
 	if err := ch.QueueBind(
@@ -76,31 +219,135 @@ func NewRabbit(url, exchange, queue string) (*Client, error) {
 		nil,
 	); err != nil {
 		zlog.Logger.Error().Err(err).Msg("failed to bind queue")
-		return nil, err
+		return err
 	}
 
-	zlog.Logger.Info().Msgf("RabbitMQ initialized (exchange=%s, queue=%s)", exchange, queue)
+	if err := ch.ExchangeDeclare(dlx, "direct", true, false, false, false, nil); err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to declare dead-letter exchange")
+		return err
+	}
+	if _, err := ch.QueueDeclare(dlq, true, false, false, false, nil); err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to declare dead-letter queue")
+		return err
+	}
+	if err := ch.QueueBind(dlq, "", dlx, false, nil); err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to bind dead-letter queue")
+		return err
+	}
+	return nil
+}
 
-	return client, nil
+func (c *Client) deadLetterExchange() string {
+	return c.exchange + ".dlx"
 }
 
-func (c *Client) Close() {
-	if c.channel != nil {
-		_ = c.channel.Close()
+func (c *Client) deadLetterQueue() string {
+	return c.queue + ".dlq"
+}
+
+// watch waits for the connection or one of its channels to close and reconnects.
+func (c *Client) watch() {
+	for {
+		c.mu.RLock()
+		conn, ch, publishCh := c.conn, c.channel, c.publishChannel
+		c.mu.RUnlock()
+
+		connClosed := conn.NotifyClose(make(chan *amqp.Error, 1))
+		chClosed := ch.NotifyClose(make(chan *amqp.Error, 1))
+		publishClosed := publishCh.NotifyClose(make(chan *amqp.Error, 1))
+
+		var reason *amqp.Error
+		select {
+		case <-c.done:
+			return
+		case reason = <-connClosed:
+		case reason = <-chClosed:
+		case reason = <-publishClosed:
+		}
+
+		c.healthy.Store(false)
+		// a channel can be closed on its own (e.g. by a channel exception); start over with a
+		// fresh connection so that both channels and all consumers are recreated together
+		_ = conn.Close()
+
+		select {
+		case <-c.done:
+			return
+		default:
+		}
+
+		zlog.Logger.Warn().Msgf("RabbitMQ connection lost: %v; reconnecting", reason)
+		c.reconnect()
+	}
+}
+
+func (c *Client) reconnect() {
+	delay := minReconnectDelay
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-time.After(delay):
+		}
+
+		if err := c.connect(); err != nil {
+			delay = min(delay*2, maxReconnectDelay)
+			zlog.Logger.Warn().Err(err).Msgf("RabbitMQ reconnect failed, next attempt in %s", delay)
+			continue
+		}
+
+		zlog.Logger.Info().Msg("RabbitMQ connection restored")
+		return
 	}
-	if c.conn != nil {
-		_ = c.conn.Close()
+}
+
+// Healthy reports whether the client is connected. It is false while a reconnect is in progress.
+func (c *Client) Healthy() bool {
+	return c.healthy.Load()
+}
+
+func (c *Client) Close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.healthy.Store(false)
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.publishChannel != nil {
+			_ = c.publishChannel.Close()
+		}
+		if c.channel != nil {
+			_ = c.channel.Close()
+		}
+		if c.conn != nil {
+			_ = c.conn.Close()
+		}
+		zlog.Logger.Info().Msg("RabbitMQ connection closed")
+	})
+}
+
+func (c *Client) currentPublishChannel() (*amqp.Channel, error) {
+	if !c.healthy.Load() {
+		return nil, ErrNotConnected
 	}
-	zlog.Logger.Info().Msg("RabbitMQ connection closed")
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.publishChannel, nil
 }
 
 func (c *Client) Publish(message []byte, delaySeconds int) error {
+	ch, err := c.currentPublishChannel()
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to publish message to RabbitMQ")
+		return err
+	}
+
 	args := amqp.Table{}
 	if delaySeconds > 0 {
-		args["x-delay"] = int32(delaySeconds * 1000)
+		args["x-delay"] = delayHeader(time.Duration(delaySeconds) * time.Second)
 	}
 
-	err := c.channel.Publish(
+	err = ch.Publish(
 		c.exchange,
 		"",
 		false,
@@ -121,10 +368,101 @@ func (c *Client) Publish(message []byte, delaySeconds int) error {
 	return err
 }
 
-func (c *Client) Consume(handler func([]byte) error) error {
-	msgs, err := c.channel.Consume(
+// PublishConfirmed publishes a persistent message and waits until the broker confirms it.
+// A nil error means the message is safely stored by RabbitMQ. A delay over MaxDelay is cut to
+// MaxDelay, so the consumer of such a message has to check whether it arrived early.
+func (c *Client) PublishConfirmed(ctx context.Context, message []byte, delay time.Duration) error {
+	headers := amqp.Table{}
+	if delay > 0 {
+		headers["x-delay"] = delayHeader(delay)
+	}
+
+	err := c.publishConfirmed(ctx, c.exchange, amqp.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		Body:         message,
+		Timestamp:    time.Now(),
+		Headers:      headers,
+	})
+	if err != nil {
+		return err
+	}
+
+	zlog.Logger.Debug().Msgf("Message confirmed by exchange=%s delay=%s", c.exchange, delay)
+	return nil
+}
+
+func (c *Client) publishConfirmed(ctx context.Context, exchange string, msg amqp.Publishing) error {
+	ch, err := c.currentPublishChannel()
+	if err != nil {
+		return err
+	}
+
+	confirm, err := ch.PublishWithDeferredConfirmWithContext(ctx, exchange, "", false, false, msg)
+	if err != nil {
+		return err
+	}
+
+	acked, err := confirm.WaitContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !acked {
+		return ErrPublishNacked
+	}
+	return nil
+}
+
+// Consume delivers messages from the queue to handler on Options.Workers goroutines until ctx is
+// done, resubscribing after every reconnect. It then cancels the subscription, so the broker sends
+// nothing more, and returns once the workers have handled and acked every delivery already
+// prefetched, up to Options.Prefetch of them.
+func (c *Client) Consume(ctx context.Context, handler func([]byte) error) error {
+	cons := &consumer{tag: "eventbooker-" + newMessageID(), handler: handler}
+
+	c.mu.Lock()
+	c.consumers = append(c.consumers, cons)
+	if !c.healthy.Load() {
+		zlog.Logger.Warn().Msgf("RabbitMQ is disconnected, consumer for queue %s will start after reconnect", c.queue)
+	} else if err := c.subscribe(c.channel, cons); err != nil {
+		// the channel is broken, so the watcher reconnects and subscribes again
+		zlog.Logger.Warn().Err(err).Msg("consumer will start after reconnect")
+	}
+	c.mu.Unlock()
+
+	<-ctx.Done()
+
+	c.mu.Lock()
+	c.consumers = slices.DeleteFunc(c.consumers, func(other *consumer) bool { return other == cons })
+	if c.healthy.Load() {
+		if err := c.channel.Cancel(cons.tag, false); err != nil {
+			zlog.Logger.Warn().Err(err).Msg("failed to cancel RabbitMQ consumer")
+		}
+	}
+	c.mu.Unlock()
+
+	cons.workers.Wait()
+	zlog.Logger.Info().Msgf("Stopped consuming from queue %s, in-flight messages drained", c.queue)
+	return nil
+}
+
+// delayHeader converts a delay to the x-delay header, clamped to MaxDelay.
+func delayHeader(delay time.Duration) int32 {
+	return int32(min(delay, MaxDelay).Milliseconds())
+}
+
+type consumer struct {
+	tag     string
+	handler func([]byte) error
+	// workers counts the goroutines handling deliveries, across reconnects.
+	workers sync.WaitGroup
+}
+
+// subscribe starts delivering messages from ch to cons. The caller must hold c.mu.
+func (c *Client) subscribe(ch *amqp.Channel, cons *consumer) error {
+	msgs, err := ch.Consume(
 		c.queue,
-		"",
+		cons.tag,
 		false,
 		false,
 		false,
@@ -136,17 +474,133 @@ func (c *Client) Consume(handler func([]byte) error) error {
 		return err
 	}
 
-	go func() {
-		for d := range msgs {
-			if err := handler(d.Body); err != nil {
-				zlog.Logger.Warn().Msgf("failed to process message: %v", err)
-				_ = d.Nack(false, true)
-				continue
+	for range c.opts.Workers {
+		cons.workers.Add(1)
+		go func() {
+			defer cons.workers.Done()
+			for d := range msgs {
+				c.handle(d, cons.handler)
 			}
-			_ = d.Ack(false)
-		}
-	}()
+		}()
+	}
 
-	zlog.Logger.Info().Msgf("Started consuming from queue %s", c.queue)
+	zlog.Logger.Info().Msgf("Started consuming from queue %s (workers=%d, prefetch=%d)", c.queue, c.opts.Workers, c.opts.Prefetch)
 	return nil
 }
+
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks a handler error that retrying cannot fix, such as a malformed message.
+// The message goes straight to the dead-letter queue.
+func Permanent(err error) error {
+	return permanentError{err: err}
+}
+
+func (c *Client) handle(d amqp.Delivery, handler func([]byte) error) {
+	err := handler(d.Body)
+	if err == nil {
+		_ = d.Ack(false)
+		return
+	}
+
+	attempt := retryCount(d.Headers) + 1
+	var permanent permanentError
+	if errors.As(err, &permanent) || attempt >= c.opts.MaxAttempts {
+		c.deadLetter(d, attempt, err)
+		return
+	}
+	c.retry(d, attempt, err)
+}
+
+// retry publishes the message again through the delayed exchange and acks the original once the
+// copy is confirmed. If the copy cannot be published, the original is requeued instead.
+func (c *Client) retry(d amqp.Delivery, attempt int, cause error) {
+	delay := c.opts.RetryDelay
+	for i := 1; i < attempt && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, maxRetryDelay)
+
+	headers := copyHeaders(d.Headers)
+	headers[retryCountHeader] = int32(attempt)
+	headers[lastErrorHeader] = cause.Error()
+	headers["x-delay"] = delayHeader(delay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := c.publishConfirmed(ctx, c.exchange, republishing(d, headers)); err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to schedule message retry, requeueing")
+		_ = d.Nack(false, true)
+		return
+	}
+
+	zlog.Logger.Warn().Msgf("failed to process message (attempt %d/%d), retrying in %s: %v",
+		attempt, c.opts.MaxAttempts, delay, cause)
+	_ = d.Ack(false)
+}
+
+func (c *Client) deadLetter(d amqp.Delivery, attempt int, cause error) {
+	headers := copyHeaders(d.Headers)
+	delete(headers, "x-delay")
+	headers[retryCountHeader] = int32(attempt)
+	headers[lastErrorHeader] = cause.Error()
+	headers[failedAtHeader] = time.Now().UTC().Format(time.RFC3339)
+
+	msg := republishing(d, headers)
+	if msg.MessageId == "" {
+		msg.MessageId = newMessageID()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := c.publishConfirmed(ctx, c.deadLetterExchange(), msg); err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to dead-letter message, requeueing")
+		_ = d.Nack(false, true)
+		return
+	}
+
+	zlog.Logger.Error().Msgf("message %s moved to %s after %d attempts: %v",
+		msg.MessageId, c.deadLetterQueue(), attempt, cause)
+	_ = d.Ack(false)
+}
+
+func republishing(d amqp.Delivery, headers amqp.Table) amqp.Publishing {
+	return amqp.Publishing{
+		ContentType:  d.ContentType,
+		DeliveryMode: amqp.Persistent,
+		MessageId:    d.MessageId,
+		Body:         d.Body,
+		Timestamp:    time.Now(),
+		Headers:      headers,
+	}
+}
+
+func copyHeaders(src amqp.Table) amqp.Table {
+	dst := make(amqp.Table, len(src)+3)
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
+
+func retryCount(headers amqp.Table) int {
+	switch v := headers[retryCountHeader].(type) {
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case int:
+		return v
+	default:
+		return 0
+	}
+}
+
+func newMessageID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,170 @@
+package rabbit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// maxDeadLetterScan bounds how many dead letters a single admin operation looks at.
+const maxDeadLetterScan = 1000
+
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+type DeadLetter struct {
+	ID        string         `json:"id"`
+	Body      string         `json:"body"`
+	Attempts  int            `json:"attempts"`
+	LastError string         `json:"last_error,omitempty"`
+	FailedAt  *time.Time     `json:"failed_at,omitempty"`
+	Headers   map[string]any `json:"headers,omitempty"`
+}
+
+func deadLetterFromDelivery(d *amqp.Delivery) DeadLetter {
+	dl := DeadLetter{
+		ID:       d.MessageId,
+		Body:     string(d.Body),
+		Attempts: retryCount(d.Headers),
+		Headers:  make(map[string]any, len(d.Headers)),
+	}
+	for k, v := range d.Headers {
+		dl.Headers[k] = v
+	}
+	if s, ok := d.Headers[lastErrorHeader].(string); ok {
+		dl.LastError = s
+	}
+	if s, ok := d.Headers[failedAtHeader].(string); ok {
+		if t, err := time.Parse(time.RFC3339, s); err == nil {
+			dl.FailedAt = &t
+		}
+	}
+	return dl
+}
+
+// scanDeadLetters walks the dead-letter queue with basic.get on a channel of its own. visit may
+// ack a delivery to take it out of the queue; every other delivery goes back to the queue when
+// the channel is closed. Concurrent scans see only the messages the other one is not holding.
+func (c *Client) scanDeadLetters(limit int, visit func(d *amqp.Delivery) (stop bool, err error)) error {
+	if !c.healthy.Load() {
+		return ErrNotConnected
+	}
+	c.mu.RLock()
+	conn := c.conn
+	c.mu.RUnlock()
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("failed to open channel: %w", err)
+	}
+	defer ch.Close()
+
+	for i := 0; i < min(limit, maxDeadLetterScan); i++ {
+		d, ok, err := ch.Get(c.deadLetterQueue(), false)
+		if err != nil {
+			return fmt.Errorf("failed to read dead-letter queue: %w", err)
+		}
+		if !ok {
+			return nil
+		}
+		stop, err := visit(&d)
+		if err != nil || stop {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListDeadLetters returns up to limit dead letters, oldest first, without removing them.
+func (c *Client) ListDeadLetters(limit int) ([]DeadLetter, error) {
+	letters := make([]DeadLetter, 0)
+	err := c.scanDeadLetters(limit, func(d *amqp.Delivery) (bool, error) {
+		letters = append(letters, deadLetterFromDelivery(d))
+		return false, nil
+	})
+	return letters, err
+}
+
+func (c *Client) GetDeadLetter(id string) (*DeadLetter, error) {
+	var found *DeadLetter
+	err := c.scanDeadLetters(maxDeadLetterScan, func(d *amqp.Delivery) (bool, error) {
+		if d.MessageId != id {
+			return false, nil
+		}
+		dl := deadLetterFromDelivery(d)
+		found = &dl
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, ErrDeadLetterNotFound
+	}
+	return found, nil
+}
+
+// ReplayDeadLetter publishes the dead letter to the main exchange with a fresh retry budget and
+// removes it from the dead-letter queue once the broker has confirmed the copy.
+func (c *Client) ReplayDeadLetter(ctx context.Context, id string) error {
+	found := false
+	err := c.scanDeadLetters(maxDeadLetterScan, func(d *amqp.Delivery) (bool, error) {
+		if d.MessageId != id {
+			return false, nil
+		}
+		found = true
+
+		headers := copyHeaders(d.Headers)
+		delete(headers, retryCountHeader)
+		delete(headers, lastErrorHeader)
+		delete(headers, failedAtHeader)
+		if err := c.publishConfirmed(ctx, c.exchange, republishing(*d, headers)); err != nil {
+			return true, fmt.Errorf("failed to replay dead letter: %w", err)
+		}
+		return true, d.Ack(false)
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrDeadLetterNotFound
+	}
+	return nil
+}
+
+// DeleteDeadLetter drops a single dead letter.
+func (c *Client) DeleteDeadLetter(id string) error {
+	found := false
+	err := c.scanDeadLetters(maxDeadLetterScan, func(d *amqp.Delivery) (bool, error) {
+		if d.MessageId != id {
+			return false, nil
+		}
+		found = true
+		return true, d.Ack(false)
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrDeadLetterNotFound
+	}
+	return nil
+}
+
+// PurgeDeadLetters drops every dead letter and returns how many were removed.
+func (c *Client) PurgeDeadLetters() (int, error) {
+	if !c.healthy.Load() {
+		return 0, ErrNotConnected
+	}
+	c.mu.RLock()
+	ch := c.channel
+	c.mu.RUnlock()
+
+	n, err := ch.QueuePurge(c.deadLetterQueue(), false)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge dead-letter queue: %w", err)
+	}
+	return n, nil
+}
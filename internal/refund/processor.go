@@ -0,0 +1,136 @@
+package refund
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"fifthOne/internal/model"
+	"fifthOne/internal/payment"
+	"fifthOne/internal/repo"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	defaultInterval  = 10 * time.Second
+	defaultBatchSize = 20
+	// claimLease keeps a claimed refund away from other workers while the provider call runs.
+	claimLease  = 2 * time.Minute
+	maxAttempts = 8
+	maxBackoff  = time.Hour
+)
+
+// Processor executes pending refunds through the payment provider. Several processors may run
+// against the same database: refunds are claimed in batches and the refund ID is passed to the
+// provider as the idempotency key, so a retried call never pays out twice.
+type Processor struct {
+	repo     repo.Repository
+	provider payment.Provider
+	log      *zerolog.Logger
+	interval time.Duration
+	batch    int
+
+	done   chan struct{}
+	cancel context.CancelFunc
+}
+
+func NewProcessor(repo repo.Repository, provider payment.Provider, log *zerolog.Logger) *Processor {
+	return &Processor{
+		repo:     repo,
+		provider: provider,
+		log:      log,
+		interval: defaultInterval,
+		batch:    defaultBatchSize,
+		done:     make(chan struct{}),
+	}
+}
+
+func (p *Processor) Start(ctx context.Context) {
+	cctx, cancel := context.WithCancel(ctx)
+	p.cancel = cancel
+
+	p.log.Info().Msg("💸 Refund processor started")
+
+	go func() {
+		defer close(p.done)
+
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+
+		for {
+			p.runOnce(cctx)
+
+			select {
+			case <-cctx.Done():
+				p.log.Info().Msg("🛑 Refund processor stopped by context")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (p *Processor) Stop() {
+	if p.cancel != nil {
+		p.cancel()
+		<-p.done
+	}
+}
+
+func (p *Processor) runOnce(ctx context.Context) {
+	refunds, err := p.repo.ClaimDueRefunds(ctx, p.batch, claimLease)
+	if err != nil {
+		if ctx.Err() == nil {
+			p.log.Error().Err(err).Msg("Failed to claim refunds")
+		}
+		return
+	}
+
+	for i := range refunds {
+		p.execute(ctx, &refunds[i])
+	}
+}
+
+func (p *Processor) execute(ctx context.Context, rf *model.Refund) {
+	log := p.log.With().
+		Int64("refund_id", rf.ID).
+		Int64("registration_id", rf.RegistrationID).
+		Int("attempt", rf.Attempts).
+		Logger()
+
+	result, err := p.provider.Refund(ctx, rf.ProviderIntentID, rf.Amount, fmt.Sprintf("refund-%d", rf.ID))
+	if err == nil {
+		if err := p.repo.CompleteRefund(ctx, rf.ID, result.ID); err != nil {
+			log.Error().Err(err).Msg("Refund issued but not recorded, will retry with the same key")
+			return
+		}
+		log.Info().Str("provider_refund_id", result.ID).Int64("amount", rf.Amount).Msg("✅ Refund issued")
+		return
+	}
+
+	var retryAt *time.Time
+	if !errors.Is(err, payment.ErrRefundNotPossible) && !errors.Is(err, payment.ErrIntentNotFound) && rf.Attempts < maxAttempts {
+		at := time.Now().Add(backoff(rf.Attempts))
+		retryAt = &at
+	}
+
+	if retryAt == nil {
+		log.Error().Err(err).Msg("❌ Refund failed permanently")
+	} else {
+		log.Warn().Err(err).Time("retry_at", *retryAt).Msg("Refund attempt failed")
+	}
+	if err := p.repo.FailRefund(ctx, rf.ID, err.Error(), retryAt); err != nil {
+		log.Error().Err(err).Msg("Failed to record refund failure")
+	}
+}
+
+// backoff doubles from 30 seconds per attempt, capped at maxBackoff.
+func backoff(attempt int) time.Duration {
+	d := 30 * time.Second
+	for i := 1; i < attempt && d < maxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxBackoff)
+}
@@ -0,0 +1,159 @@
+package reminder
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"time"
+
+	"fifthOne/internal/model"
+	"fifthOne/internal/notify"
+	"fifthOne/internal/repo"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	defaultInterval  = 30 * time.Second
+	defaultBatchSize = 50
+)
+
+// Scheduler queues reminder notifications for confirmed attendees at fixed offsets before an
+// event starts, e.g. 24h and 1h. Nothing is scheduled ahead: every pass looks for reminders that
+// are due by the current start time of the event, so moving an event moves its reminders, and
+// registrations canceled in the meantime are left out. Queued reminders are recorded together
+// with their notification, so a restart or a second worker does not send them twice.
+type Scheduler struct {
+	repo     repo.Repository
+	log      *zerolog.Logger
+	offsets  []time.Duration
+	interval time.Duration
+	batch    int
+
+	done   chan struct{}
+	cancel context.CancelFunc
+}
+
+// NewScheduler returns a scheduler for the given offsets before the start; interval <= 0 means the default.
+func NewScheduler(repo repo.Repository, offsets []time.Duration, interval time.Duration, log *zerolog.Logger) *Scheduler {
+	offsets = slices.Clone(offsets)
+	// largest first: each offset is due until the next smaller one is
+	slices.SortFunc(offsets, func(a, b time.Duration) int { return cmp.Compare(b, a) })
+	offsets = slices.Compact(offsets)
+
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+	return &Scheduler{
+		repo:     repo,
+		log:      log,
+		offsets:  offsets,
+		interval: interval,
+		batch:    defaultBatchSize,
+		done:     make(chan struct{}),
+	}
+}
+
+func (s *Scheduler) Start(ctx context.Context) {
+	cctx, cancel := context.WithCancel(ctx)
+	s.cancel = cancel
+
+	s.log.Info().Msgf("⏰ Reminder scheduler started, offsets: %v", s.offsets)
+
+	go func() {
+		defer close(s.done)
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			s.runOnce(cctx)
+
+			select {
+			case <-cctx.Done():
+				s.log.Info().Msg("🛑 Reminder scheduler stopped by context")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (s *Scheduler) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+		<-s.done
+	}
+}
+
+func (s *Scheduler) runOnce(ctx context.Context) {
+	for i, offset := range s.offsets {
+		var until time.Duration
+		if i+1 < len(s.offsets) {
+			until = s.offsets[i+1]
+		}
+
+		// a full batch means more reminders of this offset are probably due
+		for ctx.Err() == nil {
+			reminders, err := s.repo.DueReminders(ctx, offset, until, s.batch)
+			if err != nil {
+				if ctx.Err() == nil {
+					s.log.Error().Err(err).Dur("offset", offset).Msg("Failed to look up due reminders")
+				}
+				return
+			}
+			queued := 0
+			for j := range reminders {
+				if s.enqueue(ctx, &reminders[j]) {
+					queued++
+				}
+			}
+			// stop if nothing was queued, so a reminder that keeps failing does not spin the loop
+			if len(reminders) < s.batch || queued == 0 {
+				break
+			}
+		}
+	}
+}
+
+func (s *Scheduler) enqueue(ctx context.Context, rem *model.Reminder) bool {
+	log := s.log.With().
+		Int64("registration_id", rem.RegistrationID).
+		Int64("event_id", rem.EventID).
+		Dur("offset", rem.Offset).
+		Logger()
+
+	notifications, err := notifications(rem)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to build reminder")
+		return false
+	}
+
+	queued, err := s.repo.EnqueueReminderTx(ctx, rem, notifications)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Error().Err(err).Msg("Failed to queue reminder")
+		}
+		return false
+	}
+	if queued {
+		log.Info().Time("start_time", rem.StartTime).Msg("Reminder queued")
+	}
+	return queued
+}
+
+// notifications builds the reminder for every channel the attendee has chosen.
+func notifications(rem *model.Reminder) ([]*model.Notification, error) {
+	startTime := rem.StartTime
+	reg := &model.Registration{
+		ID:            int(rem.RegistrationID),
+		Email:         rem.Email,
+		Phone:         rem.Phone,
+		NotifyChannel: rem.NotifyChannel,
+	}
+	return notify.Queued(reg, notify.TypeReminder, notify.Data{
+		EventName: rem.EventName,
+		StartTime: &startTime,
+		Location:  rem.Location,
+	})
+}
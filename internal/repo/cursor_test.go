@@ -0,0 +1,130 @@
+package repo_test
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"fifthOne/internal/model"
+	"fifthOne/internal/repo"
+	"fifthOne/internal/repo/repotest"
+)
+
+func newFixtureRepository(t *testing.T, events []model.Event) repo.Repository {
+	t.Helper()
+	db := repotest.Open(&repotest.Driver{Events: len(events), Fixture: events})
+	t.Cleanup(func() { _ = db.Master.Close() })
+
+	log := zerolog.Nop()
+	r, err := repo.NewRepository(db, &log, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// tiedEvents start at two moments, several events each, with IDs out of start order.
+func tiedEvents() []model.Event {
+	early := time.Date(2026, 6, 1, 10, 0, 0, 0, time.UTC)
+	late := early.Add(24 * time.Hour)
+	created := early.Add(-30 * 24 * time.Hour)
+	var events []model.Event
+	for i, start := range []time.Time{late, early, late, early, early, late, early} {
+		events = append(events, model.Event{
+			ID:        i + 1,
+			Name:      "Event",
+			StartTime: start,
+			CreatedAt: created,
+		})
+	}
+	return events
+}
+
+// walk follows NextCursor from the first page to the last and returns the IDs in the order listed.
+func walk(t *testing.T, r repo.Repository, sort model.EventSort, limit int) []int {
+	t.Helper()
+	var ids []int
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > 100 {
+			t.Fatal("pagination does not end")
+		}
+		page, err := r.ListEvents(context.Background(), model.EventFilter{Sort: sort, Limit: limit, Cursor: cursor})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range page.Events {
+			ids = append(ids, e.ID)
+		}
+		if page.NextCursor == "" {
+			return ids
+		}
+		cursor = page.NextCursor
+	}
+}
+
+func TestListEventsCursorTieOnStartTime(t *testing.T) {
+	r := newFixtureRepository(t, tiedEvents())
+	tests := []struct {
+		sort model.EventSort
+		want []int
+	}{
+		// equal start times are ordered by ID, so no event is skipped or repeated at a page boundary
+		{model.SortStartTime, []int{2, 4, 5, 7, 1, 3, 6}},
+		{model.SortStartTimeDesc, []int{6, 3, 1, 7, 5, 4, 2}},
+		// every event has the same name and creation time
+		{model.SortName, []int{1, 2, 3, 4, 5, 6, 7}},
+		{model.SortCreatedAtDesc, []int{7, 6, 5, 4, 3, 2, 1}},
+	}
+	for _, tt := range tests {
+		for _, limit := range []int{1, 2, 3, 7, 10} {
+			if got := walk(t, r, tt.sort, limit); !slices.Equal(got, tt.want) {
+				t.Errorf("%s, %d per page: got %v, want %v", tt.sort, limit, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestListEventsRejectsMalformedCursor(t *testing.T) {
+	r := newFixtureRepository(t, tiedEvents())
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+
+	for name, cursor := range map[string]string{
+		"not base64":         "%%%",
+		"not json":           encode("start_time|2026-06-01"),
+		"no sort":            encode(`{"v":"2026-06-01T10:00:00Z","id":1}`),
+		"time not a time":    encode(`{"s":"start_time","v":"tomorrow","id":1}`),
+		"id not a number":    encode(`{"s":"start_time","v":"2026-06-01T10:00:00Z","id":"1"}`),
+		"truncated encoding": encode(`{"s":"start_time","v":"2026-06-01T10:00:00Z","id":1}`)[:10],
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := r.ListEvents(context.Background(), model.EventFilter{Sort: model.SortStartTime, Limit: 2, Cursor: cursor})
+			if !errors.Is(err, repo.ErrInvalidCursor) {
+				t.Fatalf("got %v, want ErrInvalidCursor", err)
+			}
+		})
+	}
+}
+
+func TestListEventsRejectsCursorOfOtherSort(t *testing.T) {
+	r := newFixtureRepository(t, tiedEvents())
+	page, err := r.ListEvents(context.Background(), model.EventFilter{Sort: model.SortStartTime, Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.NextCursor == "" {
+		t.Fatal("expected a next page")
+	}
+
+	for _, sort := range []model.EventSort{model.SortStartTimeDesc, model.SortCreatedAt, model.SortName, ""} {
+		_, err := r.ListEvents(context.Background(), model.EventFilter{Sort: sort, Limit: 2, Cursor: page.NextCursor})
+		if !errors.Is(err, repo.ErrInvalidCursor) {
+			t.Errorf("sort %q: got %v, want ErrInvalidCursor", sort, err)
+		}
+	}
+}
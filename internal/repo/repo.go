@@ -2,162 +2,251 @@ package repo
 
 import (
 	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
-	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/lib/pq"
 	"github.com/rs/zerolog"
 	"github.com/wb-go/wbf/dbpg"
-	"github.com/wb-go/wbf/ginext"
 
+	"fifthOne/internal/dto"
 	"fifthOne/internal/model"
+	"fifthOne/internal/payment"
+	"fifthOne/pkg/token"
 )
 
 var (
 	ErrEventNotFound         = errors.New("event not found")
-	ErrEventFull             = errors.New("event is full")
 	ErrDuplicateRegistration = errors.New("duplicate registration")
+
+	ErrRegistrationNotFound       = errors.New("registration not found")
+	ErrRegistrationOwnerMismatch  = errors.New("registration belongs to another attendee")
+	ErrCancellationDeadlinePassed = errors.New("cancellation deadline has passed")
+	ErrInvalidConfirmationToken   = errors.New("invalid confirmation token")
+	ErrConfirmationTokenExpired   = errors.New("confirmation token has expired")
+	ErrRegistrationNotPending     = errors.New("registration is not pending")
+
+	ErrInvalidCursor = errors.New("invalid cursor")
+
+	ErrPaymentNotFound         = errors.New("payment not found")
+	ErrWebhookAlreadyProcessed = errors.New("webhook already processed")
 )
 
 type Repository interface {
-	CreateEvent(ctx *ginext.Context, e *model.Event) (int64, error)
+	CreateEvent(ctx context.Context, e *model.Event) (int64, error)
 	GetEventByID(ctx context.Context, id int64) (*model.Event, error)
 	GetAllEvents(ctx context.Context) ([]model.Event, error)
-	BookRegistrationTx(ctx context.Context, reg *model.Registration) (int64, int, error)
+	ListEvents(ctx context.Context, f model.EventFilter) (*model.EventPage, error)
+	UpdateEvent(ctx context.Context, id int64, u model.EventUpdate) (*model.Event, error)
+	BookRegistrationTx(ctx context.Context, reg *model.Registration) (int64, *model.Event, error)
 	GetRegistrationByID(ctx context.Context, id int64) (*model.Registration, error)
-	UpdateRegistrationStatusTx(ctx context.Context, registrationID int64, newStatus string) error
+	UpdateRegistrationStatusTx(ctx context.Context, registrationID int64, newStatus model.RegistrationStatus, actor model.Actor, reason string) (*model.Registration, *model.Registration, error)
 	GetRegistrationByRegID(ctx context.Context, regID int64) (*model.Registration, error)
 	CountRegistrations(ctx context.Context, eventID int64) (int, error)
+	CountWaitlisted(ctx context.Context, eventID int64) (int, error)
 	GetRegistrationsByEventID(ctx context.Context, eventID int64) ([]model.Registration, error)
-	CancelIfNotConfirmedTx(ctx context.Context, registrationID int64) (bool, error)
-	MigrateUp(migrationsDir string) error
-	MigrateDown(migrationsDir string) error
+	GetRegistrationsByEventIDs(ctx context.Context, eventIDs []int64) (map[int64][]model.Registration, error)
+	CancelIfNotConfirmedTx(ctx context.Context, registrationID, generation int64) (bool, *model.Registration, error)
+	ExtendExpiryTx(ctx context.Context, registrationID int64, by time.Duration, actor model.Actor) (*model.Registration, error)
+	ExpireOverdueTx(ctx context.Context, limit int) ([]model.Registration, []model.Registration, error)
+	CancelRegistrationTx(ctx context.Context, eventID, registrationID int64, email string) (*model.Registration, *model.Registration, error)
+	GetRegistrationHistory(ctx context.Context, registrationID int64) ([]model.RegistrationEvent, error)
+	ConfirmByTokenTx(ctx context.Context, eventID int64, confirmationToken string) (*model.Registration, error)
+	GetRegistrationByToken(ctx context.Context, eventID int64, confirmationToken string) (*model.Registration, error)
+	CreatePayment(ctx context.Context, p *model.Payment) (int64, error)
+	GetActivePaymentByRegistrationID(ctx context.Context, registrationID int64) (*model.Payment, error)
+	HandlePaymentEventTx(ctx context.Context, provider string, event payment.WebhookEvent) (*PaymentEventResult, error)
+	ClaimDueRefunds(ctx context.Context, limit int, lease time.Duration) ([]model.Refund, error)
+	CompleteRefund(ctx context.Context, refundID int64, providerRefundID string) error
+	FailRefund(ctx context.Context, refundID int64, reason string, retryAt *time.Time) error
+	ClaimOutbox(ctx context.Context, limit int, lease time.Duration) ([]model.OutboxMessage, error)
+	MarkOutboxSent(ctx context.Context, id int64) error
+	FailOutbox(ctx context.Context, id int64, reason string, retryAt time.Time) error
+	ClaimNotifications(ctx context.Context, limit int, lease time.Duration) ([]model.Notification, error)
+	MarkNotificationSent(ctx context.Context, id int64) error
+	FailNotification(ctx context.Context, id int64, reason string, retryAt *time.Time) error
+	SkipNotification(ctx context.Context, id int64, reason string) error
+	GetContactPreferences(ctx context.Context, contact string) (*model.ContactPreferences, error)
+	OptOut(ctx context.Context, contact string, categories ...model.NotificationCategory) (*model.ContactPreferences, error)
+	DueReminders(ctx context.Context, offset, until time.Duration, limit int) ([]model.Reminder, error)
+	EnqueueReminderTx(ctx context.Context, rem *model.Reminder, notifications []*model.Notification) (bool, error)
+}
+
+// StatusChange is a registration that has just been booked, with an empty From, or moved to another
+// status. Registration.ConfirmationToken holds the plain token if a new one has been issued.
+type StatusChange struct {
+	Registration *model.Registration
+	Event        *model.Event
+	From         model.RegistrationStatus
+	Actor        model.Actor
 }
 
+// NotificationBuilder returns the notifications to send about a status change, nil if none. The
+// repository queues them in the transaction that makes the change, so they are sent if and only if
+// the change is committed.
+type NotificationBuilder func(change StatusChange) ([]*model.Notification, error)
+
 type repository struct {
-	db  *dbpg.DB
-	log *zerolog.Logger
+	db            *dbpg.DB
+	log           *zerolog.Logger
+	notifications NotificationBuilder
 }
 
-func NewRepository(db *dbpg.DB, log *zerolog.Logger) (Repository, error) {
+// NewRepository returns a repository that queues the notifications built by notifications; nil
+// queues none.
+func NewRepository(db *dbpg.DB, log *zerolog.Logger, notifications NotificationBuilder) (Repository, error) {
 	if db == nil {
 		return nil, fmt.Errorf("db cannot be nil")
 	}
 	if err := db.Master.Ping(); err != nil {
 		return nil, fmt.Errorf("failed to ping DB: %w", err)
 	}
-	return &repository{db: db, log: log}, nil
+	return &repository{db: db, log: log, notifications: notifications}, nil
 }
 
-func (r *repository) MigrateUp(migrationsDir string) error {
-	files, err := filepath.Glob(filepath.Join(migrationsDir, "*.up.sql"))
-	if err != nil {
-		return fmt.Errorf("failed to read migration files: %w", err)
-	}
+type rowScanner interface {
+	Scan(dest ...any) error
+}
 
-	for _, file := range files {
-		sqlBytes, err := ioutil.ReadFile(file)
-		if err != nil {
-			return fmt.Errorf("failed to read migration file %s: %w", file, err)
-		}
+const eventColumns = `id, name, description, start_time, end_time, location,
+		capacity, payment_timeout_minutes, cancellation_deadline_hours,
+		COALESCE(organizer_id, ''), price, currency,
+		refund_policy, refund_full_hours, refund_partial_percent, created_at, updated_at`
 
-		if _, err := r.db.ExecContext(context.Background(), string(sqlBytes)); err != nil {
-			return fmt.Errorf("failed to apply migration %s: %w", file, err)
-		}
+func scanEvent(row rowScanner, extra ...any) (*model.Event, error) {
+	var e model.Event
+	dest := append([]any{
+		&e.ID,
+		&e.Name,
+		&e.Description,
+		&e.StartTime,
+		&e.EndTime,
+		&e.Location,
+		&e.Capacity,
+		&e.PaymentTimeoutMinutes,
+		&e.CancellationDeadlineHours,
+		&e.OrganizerID,
+		&e.Price,
+		&e.Currency,
+		&e.RefundPolicy,
+		&e.RefundFullHours,
+		&e.RefundPartialPercent,
+		&e.CreatedAt,
+		&e.UpdatedAt,
+	}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
 	}
-
-	r.log.Info().Msgf("Migrations applied successfully from %s", migrationsDir)
-	return nil
+	return &e, nil
 }
 
-func (r *repository) MigrateDown(migrationsDir string) error {
-	files, err := filepath.Glob(filepath.Join(migrationsDir, "*.down.sql"))
-	if err != nil {
-		return fmt.Errorf("failed to read rollback files: %w", err)
-	}
-
-	for _, file := range files {
-		sqlBytes, err := ioutil.ReadFile(file)
-		if err != nil {
-			return fmt.Errorf("failed to read rollback file %s: %w", file, err)
-		}
+const registrationColumns = `id, event_id, full_name, email, phone, status, created_at, updated_at, expires_at, expiry_generation, notify_channel`
 
-		if _, err := r.db.ExecContext(context.Background(), string(sqlBytes)); err != nil {
-			return fmt.Errorf("failed to rollback migration %s: %w", file, err)
-		}
+func prefixedRegistrationColumns(alias string) string {
+	cols := strings.Split(registrationColumns, ", ")
+	for i, c := range cols {
+		cols[i] = alias + "." + c
 	}
+	return strings.Join(cols, ", ")
+}
 
-	r.log.Info().Msgf("Migrations rolled back successfully from %s", migrationsDir)
-	return nil
+func scanRegistration(row rowScanner, extra ...any) (*model.Registration, error) {
+	var reg model.Registration
+	dest := append([]any{
+		&reg.ID,
+		&reg.EventID,
+		&reg.FullName,
+		&reg.Email,
+		&reg.Phone,
+		&reg.Status,
+		&reg.CreatedAt,
+		&reg.UpdatedAt,
+		&reg.ExpiresAt,
+		&reg.ExpiryGeneration,
+		&reg.NotifyChannel,
+	}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	return &reg, nil
 }
 
-func (r *repository) CreateEvent(ctx *ginext.Context, e *model.Event) (int64, error) {
+func (r *repository) CreateEvent(ctx context.Context, e *model.Event) (int64, error) {
 	query := `
-		INSERT INTO events (name, description, start_time, end_time, location, capacity, payment_timeout_minutes)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO events (name, description, start_time, end_time, location, capacity,
+		                    payment_timeout_minutes, cancellation_deadline_hours, organizer_id, price, currency,
+		                    refund_policy, refund_full_hours, refund_partial_percent)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''), $10, $11, $12, $13, $14)
 		RETURNING id
 	`
 
 	row := r.db.QueryRowContext(ctx, query,
-		e.Name, e.Description, e.StartTime, e.EndTime, e.Location, e.Capacity, e.PaymentTimeoutMinutes,
+		e.Name, e.Description, e.StartTime, e.EndTime, e.Location, e.Capacity,
+		e.PaymentTimeoutMinutes, e.CancellationDeadlineHours, e.OrganizerID, e.Price, e.Currency,
+		e.RefundPolicy, e.RefundFullHours, e.RefundPartialPercent,
 	)
 
 	var id int64
 	if err := row.Scan(&id); err != nil {
-		ctx.Error(fmt.Errorf("failed to insert event: %w", err))
-		return 0, err
+		return 0, fmt.Errorf("failed to insert event: %w", err)
 	}
 	return id, nil
 }
 
 func (r *repository) GetEventByID(ctx context.Context, id int64) (*model.Event, error) {
-	query := `
-		SELECT id, name, description, start_time, end_time, location,
-		       capacity, payment_timeout_minutes, created_at, updated_at
-		FROM events WHERE id = $1
-	`
-	row := r.db.QueryRowContext(ctx, query, id)
-
-	var e model.Event
-	if err := row.Scan(
-		&e.ID, &e.Name, &e.Description, &e.StartTime, &e.EndTime, &e.Location,
-		&e.Capacity, &e.PaymentTimeoutMinutes, &e.CreatedAt, &e.UpdatedAt,
-	); err != nil {
+	query := `SELECT ` + eventColumns + ` FROM events WHERE id = $1`
+	e, err := scanEvent(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
 		return nil, ErrEventNotFound
 	}
-	return &e, nil
+	return e, nil
 }
 
-func (r *repository) GetRegistrationByID(ctx context.Context, id int64) (*model.Registration, error) {
-	query := `
-		SELECT id, event_id, full_name, email, phone, status, created_at, updated_at
-		FROM registrations
+// UpdateEvent changes the fields set in u. Reminders follow a new start time on their own, see DueReminders.
+func (r *repository) UpdateEvent(ctx context.Context, id int64, u model.EventUpdate) (*model.Event, error) {
+	e, err := scanEvent(r.db.QueryRowContext(ctx, `
+		UPDATE events
+		SET name = COALESCE($2, name),
+		    description = COALESCE($3, description),
+		    location = COALESCE($4, location),
+		    start_time = COALESCE($5, start_time),
+		    end_time = COALESCE($6, end_time),
+		    updated_at = NOW()
 		WHERE id = $1
-	`
-	row := r.db.QueryRowContext(ctx, query, id)
+		RETURNING `+eventColumns,
+		id, u.Name, u.Description, u.Location, u.StartTime, u.EndTime))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEventNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update event: %w", err)
+	}
+	return e, nil
+}
 
-	var reg model.Registration
-	if err := row.Scan(
-		&reg.ID,
-		&reg.EventID,
-		&reg.FullName,
-		&reg.Email,
-		&reg.Phone,
-		&reg.Status,
-		&reg.CreatedAt,
-		&reg.UpdatedAt,
-	); err != nil {
+func (r *repository) GetRegistrationByID(ctx context.Context, id int64) (*model.Registration, error) {
+	query := `SELECT ` + registrationColumns + ` FROM registrations WHERE id = $1`
+	reg, err := scanRegistration(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
 		return nil, fmt.Errorf("registration not found: %w", err)
 	}
 
-	return &reg, nil
+	return reg, nil
 }
 
-func (r *repository) UpdateRegistrationStatusTx(ctx context.Context, registrationID int64, newStatus string) error {
+// UpdateRegistrationStatusTx moves a registration to newStatus if the transition table allows it and
+// returns it together with the waitlisted registration promoted into a freed seat, if any.
+// Canceling a paid, confirmed registration this way schedules a full refund.
+func (r *repository) UpdateRegistrationStatusTx(ctx context.Context, registrationID int64, newStatus model.RegistrationStatus, actor model.Actor, reason string) (*model.Registration, *model.Registration, error) {
 	tx, err := r.db.Master.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to start transaction: %w", err)
+		return nil, nil, fmt.Errorf("failed to start transaction: %w", err)
 	}
 
 	defer func() {
@@ -167,30 +256,40 @@ func (r *repository) UpdateRegistrationStatusTx(ctx context.Context, registratio
 		}
 	}()
 
-	query := `
-		UPDATE registrations
-		SET status = $1, updated_at = NOW()
-		WHERE id = $2
-		RETURNING id
-	`
+	event, reg, err := r.lockRegistrationTx(ctx, tx, registrationID)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, nil, err
+	}
+	prev := reg.Status
 
-	var id int64
-	if err := tx.QueryRowContext(ctx, query, newStatus, registrationID).Scan(&id); err != nil {
+	promoted, err := r.transitionTx(ctx, tx, event, reg, newStatus, actor, reason)
+	if err != nil {
 		_ = tx.Rollback()
-		return fmt.Errorf("failed to update registration status: %w", err)
+		return nil, nil, err
+	}
+
+	if prev == model.StatusConfirmed && newStatus == model.StatusCanceled {
+		fullRefund := func(paid int64) int64 { return paid }
+		if err := r.scheduleRefundTx(ctx, tx, reg, fullRefund, reason); err != nil {
+			_ = tx.Rollback()
+			return nil, nil, err
+		}
 	}
 
 	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+		return nil, nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	return nil
+	return reg, promoted, nil
 }
 
-func (r *repository) BookRegistrationTx(ctx context.Context, reg *model.Registration) (int64, int, error) {
+// BookRegistrationTx books a seat, or a waitlist place if the event is full, and returns the new
+// registration ID together with the event as it was locked for the booking.
+func (r *repository) BookRegistrationTx(ctx context.Context, reg *model.Registration) (int64, *model.Event, error) {
 	tx, err := r.db.Master.BeginTx(ctx, nil)
 	if err != nil {
-		return 0, 0, fmt.Errorf("failed to start transaction: %w", err)
+		return 0, nil, fmt.Errorf("failed to start transaction: %w", err)
 	}
 
 	defer func() {
@@ -200,101 +299,110 @@ func (r *repository) BookRegistrationTx(ctx context.Context, reg *model.Registra
 		}
 	}()
 
-	var event model.Event
-	err = tx.QueryRowContext(ctx, `
-		SELECT id, name, capacity, payment_timeout_minutes
-		FROM events
-		WHERE id = $1
-		FOR UPDATE
-	`, reg.EventID).Scan(&event.ID, &event.Name, &event.Capacity, &event.PaymentTimeoutMinutes)
+	event, err := r.lockEventTx(ctx, tx, int64(reg.EventID))
 	if err != nil {
 		_ = tx.Rollback()
-		return 0, 0, ErrEventNotFound
+		return 0, nil, err
 	}
-	paymentTimeout := event.PaymentTimeoutMinutes
 
 	var count int
 	err = tx.QueryRowContext(ctx, `
 		SELECT COUNT(*)
 		FROM registrations
-		WHERE event_id = $1 AND status IN ('pending', 'confirmed')
+		WHERE event_id = $1 AND status IN ('pending', 'confirmed', 'checked_in')
 	`, reg.EventID).Scan(&count)
 	if err != nil {
 		_ = tx.Rollback()
-		return 0, 0, fmt.Errorf("failed to count registrations: %w", err)
-	}
-
-	if count >= event.Capacity {
-		_ = tx.Rollback()
-		return 0, 0, ErrEventFull
+		return 0, nil, fmt.Errorf("failed to count registrations: %w", err)
 	}
 
 	var existing int
 	err = tx.QueryRowContext(ctx, `
 		SELECT COUNT(*)
 		FROM registrations
-		WHERE event_id = $1 AND email = $2 AND status != 'canceled'
+		WHERE event_id = $1 AND email = $2 AND status NOT IN ('canceled', 'expired', 'refunded')
 	`, reg.EventID, reg.Email).Scan(&existing)
 	if err != nil {
 		_ = tx.Rollback()
-		return 0, 0, fmt.Errorf("failed to check duplicate registration: %w", err)
+		return 0, nil, fmt.Errorf("failed to check duplicate registration: %w", err)
 	}
 	if existing > 0 {
 		_ = tx.Rollback()
-		return 0, 0, ErrDuplicateRegistration
+		return 0, nil, ErrDuplicateRegistration
 	}
 
 	var id int64
-	reg.Status = "pending"
+	reg.Status = model.StatusPending
+	if count >= event.Capacity {
+		reg.Status = model.StatusWaitlisted
+	}
+	if reg.NotifyChannel == "" {
+		reg.NotifyChannel = model.NotifyEmail
+	}
 	err = tx.QueryRowContext(ctx, `
-		INSERT INTO registrations (event_id, full_name, email, phone, status, created_at)
-		VALUES ($1, $2, $3, $4, $5, NOW())
+		INSERT INTO registrations (event_id, full_name, email, phone, status, notify_channel, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
 		RETURNING id
-	`, reg.EventID, reg.FullName, reg.Email, reg.Phone, reg.Status).Scan(&id)
+	`, reg.EventID, reg.FullName, reg.Email, reg.Phone, reg.Status, reg.NotifyChannel).Scan(&id)
 	if err != nil {
 		_ = tx.Rollback()
-		return 0, 0, fmt.Errorf("failed to create registration: %w", err)
+		return 0, nil, fmt.Errorf("failed to create registration: %w", err)
+	}
+
+	reg.ID = int(id)
+
+	if err := r.recordStatusChangeTx(ctx, tx, id, "", reg.Status, model.ActorUser, "booked"); err != nil {
+		_ = tx.Rollback()
+		return 0, nil, err
+	}
+
+	if reg.Status == model.StatusPending {
+		if err := r.issueConfirmationTokenTx(ctx, tx, reg, event.PaymentTimeoutMinutes); err != nil {
+			_ = tx.Rollback()
+			return 0, nil, err
+		}
+		if err := r.enqueueExpiryTx(ctx, tx, reg); err != nil {
+			_ = tx.Rollback()
+			return 0, nil, err
+		}
+	}
+
+	if reg.Status == model.StatusWaitlisted {
+		err = tx.QueryRowContext(ctx, `
+			SELECT COUNT(*)
+			FROM registrations
+			WHERE event_id = $1 AND status = 'waitlisted' AND id <= $2
+		`, reg.EventID, id).Scan(&reg.WaitlistPosition)
+		if err != nil {
+			_ = tx.Rollback()
+			return 0, nil, fmt.Errorf("failed to get waitlist position: %w", err)
+		}
+	}
+
+	if err := r.notifyTx(ctx, tx, event, reg, "", model.ActorUser); err != nil {
+		_ = tx.Rollback()
+		return 0, nil, err
 	}
 
 	if err := tx.Commit(); err != nil {
-		return 0, 0, fmt.Errorf("failed to commit transaction: %w", err)
+		return 0, nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	return id, paymentTimeout, nil
+	return id, event, nil
 }
 
 func (r *repository) GetRegistrationByRegID(ctx context.Context, regID int64) (*model.Registration, error) {
-	query := `
-		SELECT id, event_id, full_name, email, phone, status, created_at, updated_at
-		FROM registrations
-		WHERE id = $1 
-	`
-	row := r.db.QueryRowContext(ctx, query, regID)
-
-	var reg model.Registration
-	if err := row.Scan(
-		&reg.ID,
-		&reg.EventID,
-		&reg.FullName,
-		&reg.Email,
-		&reg.Phone,
-		&reg.Status,
-		&reg.CreatedAt,
-		&reg.UpdatedAt,
-	); err != nil {
+	query := `SELECT ` + registrationColumns + ` FROM registrations WHERE id = $1`
+	reg, err := scanRegistration(r.db.QueryRowContext(ctx, query, regID))
+	if err != nil {
 		return nil, fmt.Errorf("registration not found: %w", err)
 	}
 
-	return &reg, nil
+	return reg, nil
 }
 
 func (r *repository) GetAllEvents(ctx context.Context) ([]model.Event, error) {
-	query := `
-		SELECT id, name, description, start_time, end_time, location,
-		       capacity, payment_timeout_minutes, created_at, updated_at
-		FROM events
-		ORDER BY created_at DESC
-	`
+	query := `SELECT ` + eventColumns + ` FROM events ORDER BY created_at DESC`
 
 	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
@@ -304,32 +412,176 @@ func (r *repository) GetAllEvents(ctx context.Context) ([]model.Event, error) {
 
 	var events []model.Event
 	for rows.Next() {
-		var e model.Event
-		if err := rows.Scan(
-			&e.ID,
-			&e.Name,
-			&e.Description,
-			&e.StartTime,
-			&e.EndTime,
-			&e.Location,
-			&e.Capacity,
-			&e.PaymentTimeoutMinutes,
-			&e.CreatedAt,
-			&e.UpdatedAt,
-		); err != nil {
+		e, err := scanEvent(rows)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan event: %w", err)
 		}
-		events = append(events, e)
+		events = append(events, *e)
 	}
 
 	return events, nil
 }
 
+const defaultEventPageSize = 20
+
+// eventCursor is where a page of the event list ends: the sort value and ID of its last event.
+type eventCursor struct {
+	Sort  model.EventSort `json:"s"`
+	Value string          `json:"v"`
+	ID    int64           `json:"id"`
+}
+
+func encodeEventCursor(sort model.EventSort, e *model.Event) string {
+	c := eventCursor{Sort: sort, ID: int64(e.ID)}
+	switch strings.TrimPrefix(string(sort), "-") {
+	case "start_time":
+		c.Value = e.StartTime.Format(time.RFC3339Nano)
+	case "created_at":
+		c.Value = e.CreatedAt.Format(time.RFC3339Nano)
+	case "name":
+		c.Value = e.Name
+	}
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// decodeEventCursor returns the sort value and ID of the cursor, which must be for sort.
+func decodeEventCursor(cursor string, sort model.EventSort) (any, int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, 0, ErrInvalidCursor
+	}
+	var c eventCursor
+	if err := json.Unmarshal(raw, &c); err != nil || c.Sort != sort {
+		return nil, 0, ErrInvalidCursor
+	}
+	if strings.TrimPrefix(string(sort), "-") == "name" {
+		return c.Value, c.ID, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, c.Value)
+	if err != nil {
+		return nil, 0, ErrInvalidCursor
+	}
+	return t, c.ID, nil
+}
+
+// ListEvents returns one page of the events matching f, with their seat counts, and the number of
+// all of them. The counts come from the same query, so a page costs two queries however long it
+// is. Pages are keyset-paginated on the sort column and the ID, so events created meanwhile do not
+// shift them.
+func (r *repository) ListEvents(ctx context.Context, f model.EventFilter) (*model.EventPage, error) {
+	if f.Sort == "" {
+		f.Sort = model.SortCreatedAtDesc
+	}
+	column := strings.TrimPrefix(string(f.Sort), "-")
+	switch column {
+	case "start_time", "created_at", "name":
+	default:
+		return nil, fmt.Errorf("unknown event sort %q", f.Sort)
+	}
+	dir, op := "ASC", ">"
+	if strings.HasPrefix(string(f.Sort), "-") {
+		dir, op = "DESC", "<"
+	}
+	if f.Limit <= 0 {
+		f.Limit = defaultEventPageSize
+	}
+
+	var where []string
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if f.StartFrom != nil {
+		where = append(where, "start_time >= "+arg(*f.StartFrom))
+	}
+	if f.StartTo != nil {
+		where = append(where, "start_time <= "+arg(*f.StartTo))
+	}
+	if f.Location != "" {
+		where = append(where, `location ILIKE '%' || `+arg(escapeLike(f.Location))+` || '%'`)
+	}
+	if f.HasSeats {
+		where = append(where, "capacity > seats.taken")
+	}
+	if f.Upcoming {
+		where = append(where, "start_time > NOW()")
+	}
+	if f.Past {
+		where = append(where, "start_time <= NOW()")
+	}
+
+	conditions := func() string {
+		if len(where) == 0 {
+			return ""
+		}
+		return " WHERE " + strings.Join(where, " AND ")
+	}
+
+	// seats is computed per event from idx_registrations_event_status
+	const from = ` FROM events
+		LEFT JOIN LATERAL (
+			SELECT COUNT(*) FILTER (WHERE r.status IN ('pending', 'confirmed', 'checked_in')) AS taken,
+			       COUNT(*) FILTER (WHERE r.status = 'waitlisted') AS waitlisted
+			FROM registrations r
+			WHERE r.event_id = events.id
+		) seats ON TRUE`
+
+	page := &model.EventPage{}
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*)`+from+conditions(), args...).Scan(&page.Total); err != nil {
+		return nil, fmt.Errorf("failed to count events: %w", err)
+	}
+
+	if f.Cursor != "" {
+		value, id, err := decodeEventCursor(f.Cursor, f.Sort)
+		if err != nil {
+			return nil, err
+		}
+		where = append(where, fmt.Sprintf("(%s, id) %s (%s, %s)", column, op, arg(value), arg(id)))
+	}
+
+	// one more than a page tells whether there is a next one
+	query := `SELECT ` + eventColumns + `, seats.taken, seats.waitlisted` + from + conditions() +
+		fmt.Sprintf(" ORDER BY %s %s, id %s LIMIT %s", column, dir, dir, arg(f.Limit+1))
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list events: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var es model.EventSeats
+		e, err := scanEvent(rows, &es.Taken, &es.Waitlisted)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan event: %w", err)
+		}
+		es.Event = *e
+		page.Events = append(page.Events, es)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list events: %w", err)
+	}
+
+	if len(page.Events) > f.Limit {
+		page.Events = page.Events[:f.Limit]
+		page.NextCursor = encodeEventCursor(f.Sort, &page.Events[f.Limit-1].Event)
+	}
+	return page, nil
+}
+
+// escapeLike makes s match literally inside a LIKE pattern.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
 func (r *repository) CountRegistrations(ctx context.Context, eventID int64) (int, error) {
 	query := `
 		SELECT COUNT(*)
 		FROM registrations
-		WHERE event_id = $1 AND status != 'canceled'
+		WHERE event_id = $1 AND status IN ('pending', 'confirmed', 'checked_in')
 	`
 
 	var count int
@@ -340,45 +592,120 @@ func (r *repository) CountRegistrations(ctx context.Context, eventID int64) (int
 	return count, nil
 }
 
-func (r *repository) GetRegistrationsByEventID(ctx context.Context, eventID int64) ([]model.Registration, error) {
+func (r *repository) CountWaitlisted(ctx context.Context, eventID int64) (int, error) {
 	query := `
-		SELECT id, event_id, full_name, email, phone, status, created_at, updated_at
+		SELECT COUNT(*)
 		FROM registrations
-		WHERE event_id = $1 AND status != 'canceled'
-		ORDER BY created_at ASC
+		WHERE event_id = $1 AND status = 'waitlisted'
+	`
+
+	var count int
+	if err := r.db.QueryRowContext(ctx, query, eventID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count waitlisted registrations: %w", err)
+	}
+
+	return count, nil
+}
+
+// GetRegistrationsByEventID lists the registrations an organizer still has to care about: active
+// ones and ended ones that owe or got a refund. Each carries its latest refund, if any.
+func (r *repository) GetRegistrationsByEventID(ctx context.Context, eventID int64) ([]model.Registration, error) {
+	regs, err := r.GetRegistrationsByEventIDs(ctx, []int64{eventID})
+	if err != nil {
+		return nil, err
+	}
+	return regs[eventID], nil
+}
+
+// GetRegistrationsByEventIDs loads the registrations of several events in one query, keyed by event ID.
+func (r *repository) GetRegistrationsByEventIDs(ctx context.Context, eventIDs []int64) (map[int64][]model.Registration, error) {
+	regs := make(map[int64][]model.Registration, len(eventIDs))
+	if len(eventIDs) == 0 {
+		return regs, nil
+	}
+
+	query := `
+		SELECT ` + prefixedRegistrationColumns("reg") + `,
+		       CASE WHEN reg.status = 'waitlisted'
+		            THEN ROW_NUMBER() OVER (PARTITION BY reg.event_id, reg.status ORDER BY reg.id)
+		            ELSE 0
+		       END AS waitlist_position,
+		       rf.id, rf.amount, rf.currency, rf.status, rf.reason, rf.created_at, rf.updated_at
+		FROM registrations reg
+		LEFT JOIN LATERAL (
+			SELECT id, amount, currency, status, reason, created_at, updated_at
+			FROM refunds
+			WHERE registration_id = reg.id
+			ORDER BY id DESC
+			LIMIT 1
+		) rf ON TRUE
+		WHERE reg.event_id = ANY($1)
+		  AND (reg.status NOT IN ('canceled', 'expired', 'refunded') OR rf.id IS NOT NULL)
+		ORDER BY reg.event_id, reg.created_at ASC
 	`
 
-	rows, err := r.db.QueryContext(ctx, query, eventID)
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(eventIDs))
 	if err != nil {
 		return nil, fmt.Errorf("failed to get registrations: %w", err)
 	}
 	defer rows.Close()
 
-	var regs []model.Registration
 	for rows.Next() {
-		var reg model.Registration
-		if err := rows.Scan(
-			&reg.ID,
-			&reg.EventID,
-			&reg.FullName,
-			&reg.Email,
-			&reg.Phone,
-			&reg.Status,
-			&reg.CreatedAt,
-			&reg.UpdatedAt,
-		); err != nil {
+		var (
+			position                 int
+			refundID, amount         sql.NullInt64
+			currency, status, reason sql.NullString
+			createdAt, updatedAt     sql.NullTime
+		)
+		reg, err := scanRegistration(rows, &position,
+			&refundID, &amount, &currency, &status, &reason, &createdAt, &updatedAt)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan registration: %w", err)
 		}
-		regs = append(regs, reg)
+		reg.WaitlistPosition = position
+		if refundID.Valid {
+			reg.Refund = &model.Refund{
+				ID:             refundID.Int64,
+				RegistrationID: int64(reg.ID),
+				Amount:         amount.Int64,
+				Currency:       currency.String,
+				Status:         model.RefundStatus(status.String),
+				Reason:         reason.String,
+				CreatedAt:      createdAt.Time,
+				UpdatedAt:      updatedAt.Time,
+			}
+		}
+		regs[int64(reg.EventID)] = append(regs[int64(reg.EventID)], *reg)
 	}
 
-	return regs, nil
+	return regs, rows.Err()
 }
 
-func (r *repository) CancelIfNotConfirmedTx(ctx context.Context, registrationID int64) (bool, error) {
+// CancelIfNotConfirmedTx expires a registration whose payment timeout has elapsed. It returns false
+// without error when the registration has already left the pending status or the expiry belongs to
+// an older generation, i.e. the deadline has been moved since it was scheduled.
+func (r *repository) CancelIfNotConfirmedTx(ctx context.Context, registrationID, generation int64) (bool, *model.Registration, error) {
+	// most stale expiries are for confirmed or extended registrations; they are filtered out here
+	// without locking the event
+	var (
+		status  model.RegistrationStatus
+		current int64
+	)
+	err := r.db.QueryRowContext(ctx,
+		`SELECT status, expiry_generation FROM registrations WHERE id = $1`, registrationID).Scan(&status, &current)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil, nil
+	}
+	if err != nil {
+		return false, nil, fmt.Errorf("failed to select registration for cancellation: %w", err)
+	}
+	if status != model.StatusPending || current != generation {
+		return false, nil, nil
+	}
+
 	tx, err := r.db.Master.BeginTx(ctx, nil)
 	if err != nil {
-		return false, fmt.Errorf("failed to start transaction: %w", err)
+		return false, nil, fmt.Errorf("failed to start transaction: %w", err)
 	}
 
 	defer func() {
@@ -388,37 +715,1242 @@ func (r *repository) CancelIfNotConfirmedTx(ctx context.Context, registrationID
 		}
 	}()
 
-	var currentStatus string
-	querySelect := `
-		SELECT status
-		FROM registrations
-		WHERE id = $1
-		FOR UPDATE
-	`
-	err = tx.QueryRowContext(ctx, querySelect, registrationID).Scan(&currentStatus)
+	event, reg, err := r.lockRegistrationTx(ctx, tx, registrationID)
 	if err != nil {
 		_ = tx.Rollback()
-		return false, fmt.Errorf("failed to select registration for cancellation: %w", err)
+		return false, nil, fmt.Errorf("failed to select registration for cancellation: %w", err)
 	}
 
-	if currentStatus == "confirmed" || currentStatus == "canceled" {
+	if !reg.Status.CanTransitionTo(model.StatusExpired) || reg.ExpiryGeneration != generation {
 		_ = tx.Rollback()
-		return false, nil
+		return false, nil, nil
 	}
 
-	queryUpdate := `
-		UPDATE registrations
-		SET status = 'canceled', updated_at = NOW()
-		WHERE id = $1
-	`
-	if _, err := tx.ExecContext(ctx, queryUpdate, registrationID); err != nil {
+	promoted, err := r.transitionTx(ctx, tx, event, reg, model.StatusExpired, model.ActorWorker, "payment timeout elapsed")
+	if err != nil {
 		_ = tx.Rollback()
-		return false, fmt.Errorf("failed to update registration status to canceled: %w", err)
+		return false, nil, err
 	}
 
 	if err := tx.Commit(); err != nil {
-		return false, fmt.Errorf("failed to commit cancellation transaction: %w", err)
+		return false, nil, fmt.Errorf("failed to commit cancellation transaction: %w", err)
+	}
+
+	return true, promoted, nil
+}
+
+// ExtendExpiryTx moves the payment deadline of a pending registration by, counting from the current
+// deadline or from now if that has already passed. The confirmation token stays valid until the new
+// deadline, and a new expiry is enqueued for the next generation, so the one scheduled before is
+// ignored when it fires.
+func (r *repository) ExtendExpiryTx(ctx context.Context, registrationID int64, by time.Duration, actor model.Actor) (*model.Registration, error) {
+	tx, err := r.db.Master.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	_, reg, err := r.lockRegistrationTx(ctx, tx, registrationID)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	if reg.Status != model.StatusPending {
+		_ = tx.Rollback()
+		return nil, ErrRegistrationNotPending
+	}
+
+	from := time.Now()
+	if reg.ExpiresAt != nil && reg.ExpiresAt.After(from) {
+		from = *reg.ExpiresAt
+	}
+	expiresAt := from.Add(by)
+
+	if err := tx.QueryRowContext(ctx, `
+		UPDATE registrations
+		SET expires_at = $1, token_expires_at = $1,
+		    expiry_generation = expiry_generation + 1, updated_at = NOW()
+		WHERE id = $2
+		RETURNING expiry_generation, updated_at
+	`, expiresAt, reg.ID).Scan(&reg.ExpiryGeneration, &reg.UpdatedAt); err != nil {
+		_ = tx.Rollback()
+		return nil, fmt.Errorf("failed to extend registration: %w", err)
 	}
+	reg.ExpiresAt = &expiresAt
 
+	reason := fmt.Sprintf("payment deadline extended to %s", expiresAt.UTC().Format(time.RFC3339))
+	if err := r.recordStatusChangeTx(ctx, tx, int64(reg.ID), reg.Status, reg.Status, actor, reason); err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+
+	if err := r.enqueueExpiryTx(ctx, tx, reg); err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit extension transaction: %w", err)
+	}
+
+	return reg, nil
+}
+
+// CancelRegistrationTx cancels an attendee's own registration and promotes the next waitlisted
+// registration into the freed seat. It returns the canceled registration and the promoted one, if any.
+func (r *repository) CancelRegistrationTx(ctx context.Context, eventID, registrationID int64, email string) (*model.Registration, *model.Registration, error) {
+	tx, err := r.db.Master.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	event, reg, err := r.lockRegistrationTx(ctx, tx, registrationID)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, nil, err
+	}
+	if int64(reg.EventID) != eventID {
+		_ = tx.Rollback()
+		return nil, nil, ErrRegistrationNotFound
+	}
+
+	if !strings.EqualFold(reg.Email, email) {
+		_ = tx.Rollback()
+		return nil, nil, ErrRegistrationOwnerMismatch
+	}
+
+	wasConfirmed := reg.Status == model.StatusConfirmed
+	if wasConfirmed {
+		deadline := event.StartTime.Add(-time.Duration(event.CancellationDeadlineHours) * time.Hour)
+		if time.Now().After(deadline) {
+			_ = tx.Rollback()
+			return nil, nil, ErrCancellationDeadlinePassed
+		}
+	}
+
+	promoted, err := r.transitionTx(ctx, tx, event, reg, model.StatusCanceled, model.ActorUser, "canceled by attendee")
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, nil, err
+	}
+
+	if wasConfirmed {
+		now := time.Now()
+		policyRefund := func(paid int64) int64 { return event.AttendeeRefundAmount(paid, now) }
+		if err := r.scheduleRefundTx(ctx, tx, reg, policyRefund, "canceled by attendee"); err != nil {
+			_ = tx.Rollback()
+			return nil, nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, fmt.Errorf("failed to commit cancellation transaction: %w", err)
+	}
+
+	return reg, promoted, nil
+}
+
+// ExpireOverdueTx expires up to limit pending registrations whose expires_at has passed and returns
+// them together with the waitlisted registrations promoted into the freed seats. Event rows are
+// locked first, as everywhere else, and with SKIP LOCKED, so concurrent sweepers work on disjoint
+// events and an event that is busy right now is simply picked up by the next sweep.
+func (r *repository) ExpireOverdueTx(ctx context.Context, limit int) ([]model.Registration, []model.Registration, error) {
+	tx, err := r.db.Master.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT `+eventColumns+` FROM events
+		WHERE id IN (
+			SELECT event_id FROM registrations
+			WHERE status = 'pending' AND expires_at <= NOW()
+		)
+		ORDER BY id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	`, limit)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, nil, fmt.Errorf("failed to lock events with overdue registrations: %w", err)
+	}
+	var events []*model.Event
+	for rows.Next() {
+		event, err := scanEvent(rows)
+		if err != nil {
+			_ = rows.Close()
+			_ = tx.Rollback()
+			return nil, nil, fmt.Errorf("failed to scan event: %w", err)
+		}
+		events = append(events, event)
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		_ = tx.Rollback()
+		return nil, nil, err
+	}
+
+	var overdue []*model.Registration
+	eventOf := make(map[int]*model.Event, len(events))
+	for _, event := range events {
+		eventOf[event.ID] = event
+		if len(overdue) >= limit {
+			break
+		}
+		rows, err := tx.QueryContext(ctx, `
+			SELECT `+registrationColumns+`
+			FROM registrations
+			WHERE event_id = $1 AND status = 'pending' AND expires_at <= NOW()
+			ORDER BY id
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		`, event.ID, limit-len(overdue))
+		if err != nil {
+			_ = tx.Rollback()
+			return nil, nil, fmt.Errorf("failed to lock overdue registrations: %w", err)
+		}
+		for rows.Next() {
+			reg, err := scanRegistration(rows)
+			if err != nil {
+				_ = rows.Close()
+				_ = tx.Rollback()
+				return nil, nil, fmt.Errorf("failed to scan registration: %w", err)
+			}
+			overdue = append(overdue, reg)
+		}
+		_ = rows.Close()
+		if err := rows.Err(); err != nil {
+			_ = tx.Rollback()
+			return nil, nil, err
+		}
+	}
+
+	var expired, promoted []model.Registration
+	for _, reg := range overdue {
+		next, err := r.transitionTx(ctx, tx, eventOf[reg.EventID], reg, model.StatusExpired, model.ActorWorker, "payment timeout elapsed (sweeper)")
+		if err != nil {
+			_ = tx.Rollback()
+			return nil, nil, err
+		}
+		expired = append(expired, *reg)
+		if next != nil {
+			promoted = append(promoted, *next)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, fmt.Errorf("failed to commit expiry sweep: %w", err)
+	}
+
+	return expired, promoted, nil
+}
+
+// lockRegistrationTx locks the registration and its event. The event row is locked first, in the
+// same order as BookRegistrationTx, so that freeing a seat and promoting from the waitlist is
+// serialized with new bookings.
+func (r *repository) lockRegistrationTx(ctx context.Context, tx *sql.Tx, registrationID int64) (*model.Event, *model.Registration, error) {
+	var eventID int64
+	err := tx.QueryRowContext(ctx, `SELECT event_id FROM registrations WHERE id = $1`, registrationID).Scan(&eventID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, ErrRegistrationNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to select registration: %w", err)
+	}
+
+	event, err := r.lockEventTx(ctx, tx, eventID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reg, err := scanRegistration(tx.QueryRowContext(ctx,
+		`SELECT `+registrationColumns+` FROM registrations WHERE id = $1 FOR UPDATE`, registrationID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, ErrRegistrationNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to lock registration: %w", err)
+	}
+
+	return event, reg, nil
+}
+
+// transitionTx moves a locked registration of event to next, enforcing the model transition table,
+// and queues the notifications about it. If the registration gave up its seat, the next waitlisted
+// registration is promoted and returned.
+func (r *repository) transitionTx(ctx context.Context, tx *sql.Tx, event *model.Event, reg *model.Registration, next model.RegistrationStatus, actor model.Actor, reason string) (*model.Registration, error) {
+	prev := reg.Status
+	if !prev.CanTransitionTo(next) {
+		return nil, &model.InvalidTransitionError{From: prev, To: next}
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE registrations
+		SET status = $1, updated_at = NOW()
+		WHERE id = $2
+	`, next, reg.ID); err != nil {
+		return nil, fmt.Errorf("failed to update registration status to %s: %w", next, err)
+	}
+	reg.Status = next
+
+	if err := r.recordStatusChangeTx(ctx, tx, int64(reg.ID), prev, next, actor, reason); err != nil {
+		return nil, err
+	}
+
+	if err := r.notifyTx(ctx, tx, event, reg, prev, actor); err != nil {
+		return nil, err
+	}
+
+	if !prev.HoldsSeat() || next.HoldsSeat() {
+		return nil, nil
+	}
+	return r.promoteNextWaitlistedTx(ctx, tx, event, actor)
+}
+
+// notifyTx queues the notifications about a status change of reg, see NotificationBuilder.
+func (r *repository) notifyTx(ctx context.Context, tx *sql.Tx, event *model.Event, reg *model.Registration, from model.RegistrationStatus, actor model.Actor) error {
+	if r.notifications == nil {
+		return nil
+	}
+	notifications, err := r.notifications(StatusChange{Registration: reg, Event: event, From: from, Actor: actor})
+	if err != nil {
+		return fmt.Errorf("failed to build notifications: %w", err)
+	}
+	for _, n := range notifications {
+		if _, err := enqueueNotification(ctx, tx, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// issueConfirmationTokenTx stores the hash of a fresh confirmation token that expires together with
+// the payment timeout, and sets the registration's expires_at to the same moment. The plain token
+// is only kept on reg so it can be handed to the attendee.
+func (r *repository) issueConfirmationTokenTx(ctx context.Context, tx *sql.Tx, reg *model.Registration, timeoutMinutes int) error {
+	plain, hash, err := token.Generate()
+	if err != nil {
+		return err
+	}
+	expiresAt := time.Now().Add(time.Duration(timeoutMinutes) * time.Minute)
+
+	if err := tx.QueryRowContext(ctx, `
+		UPDATE registrations
+		SET confirmation_token_hash = $1, token_expires_at = $2, expires_at = $2,
+		    expiry_generation = expiry_generation + 1
+		WHERE id = $3
+		RETURNING expiry_generation
+	`, hash, expiresAt, reg.ID).Scan(&reg.ExpiryGeneration); err != nil {
+		return fmt.Errorf("failed to store confirmation token: %w", err)
+	}
+
+	reg.ConfirmationToken = plain
+	reg.TokenExpiresAt = expiresAt
+	reg.ExpiresAt = &expiresAt
+	return nil
+}
+
+// ConfirmByTokenTx confirms the pending registration the token was issued for. The token is
+// single-use: its hash is cleared once the registration is confirmed.
+func (r *repository) ConfirmByTokenTx(ctx context.Context, eventID int64, confirmationToken string) (*model.Registration, error) {
+	tx, err := r.db.Master.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	found, err := r.findByToken(ctx, tx, eventID, confirmationToken)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	registrationID := int64(found.ID)
+
+	event, reg, err := r.lockRegistrationTx(ctx, tx, registrationID)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+
+	if _, err := r.transitionTx(ctx, tx, event, reg, model.StatusConfirmed, model.ActorUser, "payment confirmed"); err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE registrations
+		SET confirmation_token_hash = NULL, token_expires_at = NULL
+		WHERE id = $1
+	`, registrationID); err != nil {
+		_ = tx.Rollback()
+		return nil, fmt.Errorf("failed to clear confirmation token: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return reg, nil
+}
+
+// GetRegistrationByToken returns the registration a still valid confirmation token was issued for,
+// without consuming the token.
+func (r *repository) GetRegistrationByToken(ctx context.Context, eventID int64, confirmationToken string) (*model.Registration, error) {
+	return r.findByToken(ctx, r.db, eventID, confirmationToken)
+}
+
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func (r *repository) findByToken(ctx context.Context, q queryRower, eventID int64, confirmationToken string) (*model.Registration, error) {
+	var expiresAt time.Time
+	reg, err := scanRegistration(q.QueryRowContext(ctx, `
+		SELECT `+registrationColumns+`, token_expires_at
+		FROM registrations
+		WHERE confirmation_token_hash = $1
+	`, token.Hash(confirmationToken)), &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && int64(reg.EventID) != eventID) {
+		return nil, ErrInvalidConfirmationToken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up confirmation token: %w", err)
+	}
+	if time.Now().After(expiresAt) {
+		return nil, ErrConfirmationTokenExpired
+	}
+	reg.TokenExpiresAt = expiresAt
+	return reg, nil
+}
+
+func (r *repository) recordStatusChangeTx(ctx context.Context, tx *sql.Tx, registrationID int64, oldStatus, newStatus model.RegistrationStatus, actor model.Actor, reason string) error {
+	var old sql.NullString
+	if oldStatus != "" {
+		old = sql.NullString{String: string(oldStatus), Valid: true}
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO registration_events (registration_id, old_status, new_status, actor, reason, created_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+	`, registrationID, old, newStatus, actor, reason); err != nil {
+		return fmt.Errorf("failed to record registration status change: %w", err)
+	}
+	return nil
+}
+
+func (r *repository) GetRegistrationHistory(ctx context.Context, registrationID int64) ([]model.RegistrationEvent, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, registration_id, COALESCE(old_status, ''), new_status, actor, reason, created_at
+		FROM registration_events
+		WHERE registration_id = $1
+		ORDER BY created_at ASC, id ASC
+	`, registrationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get registration history: %w", err)
+	}
+	defer rows.Close()
+
+	var history []model.RegistrationEvent
+	for rows.Next() {
+		var e model.RegistrationEvent
+		if err := rows.Scan(
+			&e.ID,
+			&e.RegistrationID,
+			&e.OldStatus,
+			&e.NewStatus,
+			&e.Actor,
+			&e.Reason,
+			&e.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan registration history: %w", err)
+		}
+		history = append(history, e)
+	}
+
+	return history, rows.Err()
+}
+
+func (r *repository) lockEventTx(ctx context.Context, tx *sql.Tx, eventID int64) (*model.Event, error) {
+	event, err := scanEvent(tx.QueryRowContext(ctx,
+		`SELECT `+eventColumns+` FROM events WHERE id = $1 FOR UPDATE`, eventID))
+	if err != nil {
+		return nil, ErrEventNotFound
+	}
+	return event, nil
+}
+
+// promoteNextWaitlistedTx moves the oldest waitlisted registration of the event to pending
+// if a seat is free, and queues the notification with its new confirmation token. The caller must
+// hold the event row lock. Returns nil when nothing was promoted.
+func (r *repository) promoteNextWaitlistedTx(ctx context.Context, tx *sql.Tx, event *model.Event, actor model.Actor) (*model.Registration, error) {
+	eventID := int64(event.ID)
+	var capacity, active, paymentTimeout int
+	err := tx.QueryRowContext(ctx, `
+		SELECT e.capacity, e.payment_timeout_minutes,
+		       (SELECT COUNT(*) FROM registrations
+		        WHERE event_id = e.id AND status IN ('pending', 'confirmed', 'checked_in'))
+		FROM events e
+		WHERE e.id = $1
+	`, eventID).Scan(&capacity, &paymentTimeout, &active)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count active registrations: %w", err)
+	}
+	if active >= capacity {
+		return nil, nil
+	}
+
+	reg, err := scanRegistration(tx.QueryRowContext(ctx, `
+		UPDATE registrations
+		SET status = $2, updated_at = NOW()
+		WHERE id = (
+			SELECT id FROM registrations
+			WHERE event_id = $1 AND status = $3
+			ORDER BY id
+			LIMIT 1
+			FOR UPDATE
+		)
+		RETURNING `+registrationColumns, eventID, model.StatusPending, model.StatusWaitlisted))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to promote waitlisted registration: %w", err)
+	}
+
+	if err := r.recordStatusChangeTx(ctx, tx, int64(reg.ID), model.StatusWaitlisted, model.StatusPending, actor, "promoted from waitlist"); err != nil {
+		return nil, err
+	}
+
+	if err := r.issueConfirmationTokenTx(ctx, tx, reg, paymentTimeout); err != nil {
+		return nil, err
+	}
+
+	if err := r.enqueueExpiryTx(ctx, tx, reg); err != nil {
+		return nil, err
+	}
+
+	if err := r.notifyTx(ctx, tx, event, reg, model.StatusWaitlisted, actor); err != nil {
+		return nil, err
+	}
+
+	return reg, nil
+}
+
+const paymentColumns = `id, registration_id, provider, provider_intent_id, amount, currency,
+		status, checkout_url, created_at, updated_at`
+
+func scanPayment(row rowScanner) (*model.Payment, error) {
+	var p model.Payment
+	if err := row.Scan(
+		&p.ID,
+		&p.RegistrationID,
+		&p.Provider,
+		&p.ProviderIntentID,
+		&p.Amount,
+		&p.Currency,
+		&p.Status,
+		&p.CheckoutURL,
+		&p.CreatedAt,
+		&p.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *repository) CreatePayment(ctx context.Context, p *model.Payment) (int64, error) {
+	var id int64
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO payments (registration_id, provider, provider_intent_id, amount, currency, status, checkout_url)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`, p.RegistrationID, p.Provider, p.ProviderIntentID, p.Amount, p.Currency, p.Status, p.CheckoutURL).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert payment: %w", err)
+	}
+	p.ID = id
+	return id, nil
+}
+
+// GetActivePaymentByRegistrationID returns the latest payment of the registration that has not failed.
+func (r *repository) GetActivePaymentByRegistrationID(ctx context.Context, registrationID int64) (*model.Payment, error) {
+	p, err := scanPayment(r.db.QueryRowContext(ctx, `
+		SELECT `+paymentColumns+`
+		FROM payments
+		WHERE registration_id = $1 AND status <> $2
+		ORDER BY id DESC
+		LIMIT 1
+	`, registrationID, payment.StatusFailed))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrPaymentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payment: %w", err)
+	}
+	return p, nil
+}
+
+// PaymentEventResult describes the payment and registration after HandlePaymentEventTx.
+type PaymentEventResult struct {
+	Payment      *model.Payment
+	Registration *model.Registration
+	// PreviousStatus is the registration status before the event was applied.
+	PreviousStatus model.RegistrationStatus
+}
+
+// HandlePaymentEventTx applies a verified provider event to the payment and its registration in
+// one transaction. The event ID is recorded in processed_webhooks in the same transaction, so a
+// redelivered event returns ErrWebhookAlreadyProcessed and changes nothing. Rows are locked in the
+// same order as CancelIfNotConfirmedTx, so a payment racing the expiry worker either confirms the
+// registration first or finds it already expired; in the latter case the registration is returned
+// unchanged and the caller has to return the money.
+func (r *repository) HandlePaymentEventTx(ctx context.Context, provider string, event payment.WebhookEvent) (*PaymentEventResult, error) {
+	tx, err := r.db.Master.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO processed_webhooks (provider, event_id, event_type, processed_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (provider, event_id) DO NOTHING
+	`, provider, event.ID, event.Type)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, fmt.Errorf("failed to record webhook: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		_ = tx.Rollback()
+		return nil, ErrWebhookAlreadyProcessed
+	}
+
+	var registrationID int64
+	err = tx.QueryRowContext(ctx, `
+		SELECT registration_id FROM payments WHERE provider = $1 AND provider_intent_id = $2
+	`, provider, event.IntentID).Scan(&registrationID)
+	if errors.Is(err, sql.ErrNoRows) {
+		_ = tx.Rollback()
+		return nil, ErrPaymentNotFound
+	}
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, fmt.Errorf("failed to find payment: %w", err)
+	}
+
+	regEvent, reg, err := r.lockRegistrationTx(ctx, tx, registrationID)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+
+	p, err := scanPayment(tx.QueryRowContext(ctx, `
+		SELECT `+paymentColumns+`
+		FROM payments
+		WHERE provider = $1 AND provider_intent_id = $2
+		FOR UPDATE
+	`, provider, event.IntentID))
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, fmt.Errorf("failed to lock payment: %w", err)
+	}
+
+	result := &PaymentEventResult{Payment: p, Registration: reg, PreviousStatus: reg.Status}
+
+	switch event.Type {
+	case payment.EventPaymentSucceeded:
+		if p.Status != payment.StatusRefunded {
+			err = r.setPaymentStatusTx(ctx, tx, p, payment.StatusSucceeded)
+		}
+		if err == nil && p.Status == payment.StatusSucceeded && reg.Status == model.StatusPending {
+			_, err = r.transitionTx(ctx, tx, regEvent, reg, model.StatusConfirmed, model.ActorProvider, "payment succeeded")
+			if err == nil {
+				_, err = tx.ExecContext(ctx, `
+					UPDATE registrations
+					SET confirmation_token_hash = NULL, token_expires_at = NULL
+					WHERE id = $1
+				`, reg.ID)
+			}
+		}
+		// The money arrived after the registration expired or was canceled: give it back.
+		if err == nil && p.Status == payment.StatusSucceeded && !reg.Status.HoldsSeat() {
+			fullRefund := func(paid int64) int64 { return paid }
+			err = r.scheduleRefundTx(ctx, tx, reg, fullRefund, "payment received after the registration ended")
+		}
+	case payment.EventPaymentFailed:
+		if p.Status == payment.StatusRequiresPayment || p.Status == payment.StatusAuthorized {
+			err = r.setPaymentStatusTx(ctx, tx, p, payment.StatusFailed)
+		}
+	case payment.EventRefundSucceeded:
+		if event.Amount >= p.Amount {
+			err = r.setPaymentStatusTx(ctx, tx, p, payment.StatusRefunded)
+		}
+		if err == nil {
+			// The refund job may not have stored the provider refund ID yet, so match by payment.
+			_, err = tx.ExecContext(ctx, `
+				UPDATE refunds
+				SET status = $1, provider_refund_id = COALESCE(provider_refund_id, NULLIF($2, '')), updated_at = NOW()
+				WHERE payment_id = $3
+			`, model.RefundSucceeded, event.RefundID, p.ID)
+		}
+		if err == nil && reg.Status.CanTransitionTo(model.StatusRefunded) {
+			_, err = r.transitionTx(ctx, tx, regEvent, reg, model.StatusRefunded, model.ActorProvider, "payment refunded")
+		}
+	}
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, fmt.Errorf("failed to apply %s: %w", event.Type, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return result, nil
+}
+
+func (r *repository) setPaymentStatusTx(ctx context.Context, tx *sql.Tx, p *model.Payment, status payment.Status) error {
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE payments
+		SET status = $1, updated_at = NOW()
+		WHERE id = $2
+	`, status, p.ID); err != nil {
+		return fmt.Errorf("failed to update payment status: %w", err)
+	}
+	p.Status = status
+	return nil
+}
+
+const refundColumns = `id, registration_id, payment_id, amount, currency, status, reason,
+		COALESCE(provider_refund_id, ''), attempts, last_error, next_attempt_at, created_at, updated_at`
+
+func scanRefund(row rowScanner, extra ...any) (*model.Refund, error) {
+	var rf model.Refund
+	dest := append([]any{
+		&rf.ID,
+		&rf.RegistrationID,
+		&rf.PaymentID,
+		&rf.Amount,
+		&rf.Currency,
+		&rf.Status,
+		&rf.Reason,
+		&rf.ProviderRefundID,
+		&rf.Attempts,
+		&rf.LastError,
+		&rf.NextAttemptAt,
+		&rf.CreatedAt,
+		&rf.UpdatedAt,
+	}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	return &rf, nil
+}
+
+// scheduleRefundTx records a pending refund for the registration's captured payment; amount maps
+// the paid sum to the part that is returned. Free registrations and zero amounts get no refund.
+// A payment is refunded at most once, so an existing refund is kept instead of adding another.
+func (r *repository) scheduleRefundTx(ctx context.Context, tx *sql.Tx, reg *model.Registration, amount func(paid int64) int64, reason string) error {
+	p, err := scanPayment(tx.QueryRowContext(ctx, `
+		SELECT `+paymentColumns+`
+		FROM payments
+		WHERE registration_id = $1 AND status = $2
+		ORDER BY id DESC
+		LIMIT 1
+		FOR UPDATE
+	`, reg.ID, payment.StatusSucceeded))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get payment for refund: %w", err)
+	}
+
+	refundAmount := min(amount(p.Amount), p.Amount)
+	if refundAmount <= 0 {
+		return nil
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO refunds (registration_id, payment_id, amount, currency, status, reason)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (payment_id) DO NOTHING
+	`, reg.ID, p.ID, refundAmount, p.Currency, model.RefundPending, reason); err != nil {
+		return fmt.Errorf("failed to schedule refund: %w", err)
+	}
+
+	refund, err := scanRefund(tx.QueryRowContext(ctx,
+		`SELECT `+refundColumns+` FROM refunds WHERE payment_id = $1`, p.ID))
+	if err != nil {
+		return fmt.Errorf("failed to read refund: %w", err)
+	}
+	reg.Refund = refund
+	return nil
+}
+
+// ClaimDueRefunds hands out up to limit pending refunds whose next attempt is due and hides them
+// from other claimers for lease. SKIP LOCKED lets several workers claim batches concurrently.
+func (r *repository) ClaimDueRefunds(ctx context.Context, limit int, lease time.Duration) ([]model.Refund, error) {
+	rows, err := r.db.Master.QueryContext(ctx, `
+		UPDATE refunds rf
+		SET attempts = rf.attempts + 1,
+		    next_attempt_at = NOW() + $2::int * INTERVAL '1 second',
+		    updated_at = NOW()
+		FROM payments p
+		WHERE p.id = rf.payment_id
+		  AND rf.id IN (
+			SELECT id FROM refunds
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		  )
+		RETURNING rf.id, rf.registration_id, rf.payment_id, rf.amount, rf.currency, rf.status, rf.reason,
+		          COALESCE(rf.provider_refund_id, ''), rf.attempts, rf.last_error, rf.next_attempt_at,
+		          rf.created_at, rf.updated_at, p.provider_intent_id
+	`, limit, int64(lease.Seconds()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim refunds: %w", err)
+	}
+	defer rows.Close()
+
+	var refunds []model.Refund
+	for rows.Next() {
+		var intentID string
+		rf, err := scanRefund(rows, &intentID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan refund: %w", err)
+		}
+		rf.ProviderIntentID = intentID
+		refunds = append(refunds, *rf)
+	}
+	return refunds, rows.Err()
+}
+
+func (r *repository) CompleteRefund(ctx context.Context, refundID int64, providerRefundID string) error {
+	if _, err := r.db.ExecContext(ctx, `
+		UPDATE refunds
+		SET status = $1, provider_refund_id = $2, last_error = '', updated_at = NOW()
+		WHERE id = $3
+	`, model.RefundSucceeded, providerRefundID, refundID); err != nil {
+		return fmt.Errorf("failed to complete refund: %w", err)
+	}
+	return nil
+}
+
+// FailRefund records a failed attempt. A nil retryAt gives up on the refund for good.
+func (r *repository) FailRefund(ctx context.Context, refundID int64, reason string, retryAt *time.Time) error {
+	var err error
+	if retryAt == nil {
+		_, err = r.db.ExecContext(ctx, `
+			UPDATE refunds
+			SET status = $1, last_error = $2, updated_at = NOW()
+			WHERE id = $3
+		`, model.RefundFailed, reason, refundID)
+	} else {
+		_, err = r.db.ExecContext(ctx, `
+			UPDATE refunds
+			SET last_error = $1, next_attempt_at = $2, updated_at = NOW()
+			WHERE id = $3
+		`, reason, *retryAt, refundID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record refund failure: %w", err)
+	}
+	return nil
+}
+
+// enqueueExpiryTx writes the message that expires reg once its confirmation token runs out.
+// It is stored in the outbox within the caller's transaction, so it exists if and only if
+// the pending registration does.
+func (r *repository) enqueueExpiryTx(ctx context.Context, tx *sql.Tx, reg *model.Registration) error {
+	payload, err := json.Marshal(dto.RegistrationOperateMessage{
+		RegistrationID: int64(reg.ID),
+		EventID:        int64(reg.EventID),
+		ExpireAt:       *reg.ExpiresAt,
+		Generation:     reg.ExpiryGeneration,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal expiry message: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO outbox (topic, payload, deliver_at)
+		VALUES ($1, $2, $3)
+	`, model.OutboxTopicRegistrationExpiry, payload, *reg.ExpiresAt); err != nil {
+		return fmt.Errorf("failed to enqueue expiry message: %w", err)
+	}
+	return nil
+}
+
+// ClaimOutbox hands out up to limit unsent messages in insertion order and hides them from
+// other relays for lease, the same way ClaimDueRefunds does for refunds.
+func (r *repository) ClaimOutbox(ctx context.Context, limit int, lease time.Duration) ([]model.OutboxMessage, error) {
+	rows, err := r.db.Master.QueryContext(ctx, `
+		UPDATE outbox
+		SET attempts = attempts + 1,
+		    next_attempt_at = NOW() + $2::int * INTERVAL '1 second'
+		WHERE id IN (
+			SELECT id FROM outbox
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, topic, payload, deliver_at, attempts, last_error, next_attempt_at, created_at, sent_at
+	`, limit, int64(lease.Seconds()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim outbox messages: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []model.OutboxMessage
+	for rows.Next() {
+		var m model.OutboxMessage
+		var sentAt sql.NullTime
+		if err := rows.Scan(&m.ID, &m.Topic, &m.Payload, &m.DeliverAt, &m.Attempts, &m.LastError,
+			&m.NextAttemptAt, &m.CreatedAt, &sentAt); err != nil {
+			return nil, fmt.Errorf("failed to scan outbox message: %w", err)
+		}
+		if sentAt.Valid {
+			m.SentAt = &sentAt.Time
+		}
+		messages = append(messages, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// UPDATE ... RETURNING does not keep the subquery order.
+	sort.Slice(messages, func(i, j int) bool { return messages[i].ID < messages[j].ID })
+	return messages, nil
+}
+
+func (r *repository) MarkOutboxSent(ctx context.Context, id int64) error {
+	if _, err := r.db.ExecContext(ctx, `
+		UPDATE outbox
+		SET status = 'sent', sent_at = NOW(), last_error = ''
+		WHERE id = $1
+	`, id); err != nil {
+		return fmt.Errorf("failed to mark outbox message sent: %w", err)
+	}
+	return nil
+}
+
+// FailOutbox records a failed publish. Outbox messages are never given up on: the relay keeps
+// retrying until the broker accepts them.
+func (r *repository) FailOutbox(ctx context.Context, id int64, reason string, retryAt time.Time) error {
+	if _, err := r.db.ExecContext(ctx, `
+		UPDATE outbox
+		SET last_error = $1, next_attempt_at = $2
+		WHERE id = $3
+	`, reason, retryAt, id); err != nil {
+		return fmt.Errorf("failed to record outbox failure: %w", err)
+	}
+	return nil
+}
+
+const notificationColumns = `id, COALESCE(registration_id, 0), type, channel, recipient, payload, status, attempts,
+		last_error, next_attempt_at, created_at, sent_at`
+
+func scanNotification(row rowScanner) (*model.Notification, error) {
+	var n model.Notification
+	var sentAt sql.NullTime
+	if err := row.Scan(&n.ID, &n.RegistrationID, &n.Type, &n.Channel, &n.Recipient, &n.Payload, &n.Status, &n.Attempts,
+		&n.LastError, &n.NextAttemptAt, &n.CreatedAt, &sentAt); err != nil {
+		return nil, err
+	}
+	if sentAt.Valid {
+		n.SentAt = &sentAt.Time
+	}
+	return &n, nil
+}
+
+func enqueueNotification(ctx context.Context, q queryRower, n *model.Notification) (int64, error) {
+	var registrationID sql.NullInt64
+	if n.RegistrationID != 0 {
+		registrationID = sql.NullInt64{Int64: n.RegistrationID, Valid: true}
+	}
+
+	channel := n.Channel
+	if channel == "" {
+		channel = model.NotifyEmail
+	}
+
+	var id int64
+	if err := q.QueryRowContext(ctx, `
+		INSERT INTO notifications (registration_id, type, channel, recipient, payload)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`, registrationID, n.Type, channel, n.Recipient, n.Payload).Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to enqueue notification: %w", err)
+	}
+	return id, nil
+}
+
+// ClaimNotifications hands out up to limit due notifications, oldest first, and hides them from
+// other dispatchers for lease, the same way ClaimDueRefunds does for refunds.
+func (r *repository) ClaimNotifications(ctx context.Context, limit int, lease time.Duration) ([]model.Notification, error) {
+	rows, err := r.db.Master.QueryContext(ctx, `
+		UPDATE notifications
+		SET attempts = attempts + 1,
+		    next_attempt_at = NOW() + $2::int * INTERVAL '1 second'
+		WHERE id IN (
+			SELECT id FROM notifications
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+notificationColumns+`
+	`, limit, int64(lease.Seconds()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim notifications: %w", err)
+	}
+	defer rows.Close()
+
+	var notifications []model.Notification
+	for rows.Next() {
+		n, err := scanNotification(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan notification: %w", err)
+		}
+		notifications = append(notifications, *n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// UPDATE ... RETURNING does not keep the subquery order.
+	sort.Slice(notifications, func(i, j int) bool { return notifications[i].ID < notifications[j].ID })
+	return notifications, nil
+}
+
+// MarkNotificationSent records a delivered notification. The sealed link is dropped from the
+// payload, as nothing needs it once the notification is closed.
+func (r *repository) MarkNotificationSent(ctx context.Context, id int64) error {
+	if _, err := r.db.ExecContext(ctx, `
+		UPDATE notifications
+		SET status = $1, sent_at = NOW(), last_error = '', payload = payload - 'sealed_link'
+		WHERE id = $2
+	`, model.NotificationSent, id); err != nil {
+		return fmt.Errorf("failed to mark notification sent: %w", err)
+	}
+	return nil
+}
+
+// FailNotification records a failed attempt. A nil retryAt gives up on the notification for good.
+func (r *repository) FailNotification(ctx context.Context, id int64, reason string, retryAt *time.Time) error {
+	var err error
+	if retryAt == nil {
+		_, err = r.db.ExecContext(ctx, `
+			UPDATE notifications
+			SET status = $1, last_error = $2, payload = payload - 'sealed_link'
+			WHERE id = $3
+		`, model.NotificationFailed, reason, id)
+	} else {
+		_, err = r.db.ExecContext(ctx, `
+			UPDATE notifications
+			SET last_error = $1, next_attempt_at = $2
+			WHERE id = $3
+		`, reason, *retryAt, id)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record notification failure: %w", err)
+	}
+	return nil
+}
+
+// SkipNotification closes a notification that is not going to be sent, e.g. because the
+// recipient has opted out.
+func (r *repository) SkipNotification(ctx context.Context, id int64, reason string) error {
+	if _, err := r.db.ExecContext(ctx, `
+		UPDATE notifications
+		SET status = $1, last_error = $2, payload = payload - 'sealed_link'
+		WHERE id = $3
+	`, model.NotificationSkipped, reason, id); err != nil {
+		return fmt.Errorf("failed to skip notification: %w", err)
+	}
+	return nil
+}
+
+const contactPreferencesColumns = `contact, opt_out_reminders, opt_out_marketing, opt_out_waitlist_offers, updated_at`
+
+func scanContactPreferences(row rowScanner) (*model.ContactPreferences, error) {
+	var p model.ContactPreferences
+	if err := row.Scan(&p.Contact, &p.OptOutReminders, &p.OptOutMarketing, &p.OptOutWaitlistOffers, &p.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// GetContactPreferences returns the preferences of contact; a contact without any has opted out of nothing.
+func (r *repository) GetContactPreferences(ctx context.Context, contact string) (*model.ContactPreferences, error) {
+	contact = model.NormalizeContact(contact)
+	p, err := scanContactPreferences(r.db.QueryRowContext(ctx,
+		`SELECT `+contactPreferencesColumns+` FROM contact_preferences WHERE contact = $1`, contact))
+	if errors.Is(err, sql.ErrNoRows) {
+		return &model.ContactPreferences{Contact: contact}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get contact preferences: %w", err)
+	}
+	return p, nil
+}
+
+// OptOut adds categories to the opt-outs of contact; earlier opt-outs are kept.
+func (r *repository) OptOut(ctx context.Context, contact string, categories ...model.NotificationCategory) (*model.ContactPreferences, error) {
+	var opt model.ContactPreferences
+	for _, c := range categories {
+		switch c {
+		case model.CategoryReminders:
+			opt.OptOutReminders = true
+		case model.CategoryMarketing:
+			opt.OptOutMarketing = true
+		case model.CategoryWaitlistOffers:
+			opt.OptOutWaitlistOffers = true
+		default:
+			return nil, fmt.Errorf("cannot opt out of %q notifications", c)
+		}
+	}
+
+	p, err := scanContactPreferences(r.db.QueryRowContext(ctx, `
+		INSERT INTO contact_preferences (contact, opt_out_reminders, opt_out_marketing, opt_out_waitlist_offers)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (contact) DO UPDATE
+		SET opt_out_reminders = contact_preferences.opt_out_reminders OR EXCLUDED.opt_out_reminders,
+		    opt_out_marketing = contact_preferences.opt_out_marketing OR EXCLUDED.opt_out_marketing,
+		    opt_out_waitlist_offers = contact_preferences.opt_out_waitlist_offers OR EXCLUDED.opt_out_waitlist_offers,
+		    updated_at = NOW()
+		RETURNING `+contactPreferencesColumns+`
+	`, model.NormalizeContact(contact), opt.OptOutReminders, opt.OptOutMarketing, opt.OptOutWaitlistOffers))
+	if err != nil {
+		return nil, fmt.Errorf("failed to store contact preferences: %w", err)
+	}
+	return p, nil
+}
+
+// DueReminders returns confirmed registrations whose reminder offset before the start is due but
+// whose reminder until before the start is not yet, and which have no such reminder for the
+// current start time. Passing the next smaller configured offset as until means an attendee who
+// registers late gets only the closest reminder. Canceled registrations are not reminded.
+func (r *repository) DueReminders(ctx context.Context, offset, until time.Duration, limit int) ([]model.Reminder, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT r.id, r.event_id, r.email, COALESCE(r.phone, ''), r.notify_channel, e.name, COALESCE(e.location, ''), e.start_time
+		FROM registrations r
+		JOIN events e ON e.id = r.event_id
+		WHERE r.status = $1
+		  AND e.start_time - $2::int * INTERVAL '1 second' <= NOW()
+		  AND e.start_time - $3::int * INTERVAL '1 second' > NOW()
+		  AND NOT EXISTS (
+		      SELECT 1 FROM event_reminders er
+		      WHERE er.registration_id = r.id
+		        AND er.start_time = e.start_time
+		        AND er.offset_seconds = $2
+		  )
+		ORDER BY e.start_time, r.id
+		LIMIT $4
+	`, model.StatusConfirmed, int(offset.Seconds()), int(until.Seconds()), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query due reminders: %w", err)
+	}
+	defer rows.Close()
+
+	var reminders []model.Reminder
+	for rows.Next() {
+		rem := model.Reminder{Offset: offset}
+		if err := rows.Scan(&rem.RegistrationID, &rem.EventID, &rem.Email, &rem.Phone, &rem.NotifyChannel, &rem.EventName, &rem.Location, &rem.StartTime); err != nil {
+			return nil, fmt.Errorf("failed to scan reminder: %w", err)
+		}
+		reminders = append(reminders, rem)
+	}
+	return reminders, rows.Err()
+}
+
+// EnqueueReminderTx records the reminder and queues its notifications, one per channel, together.
+// It returns false without queueing anything if the reminder has already been recorded, e.g. by
+// another worker. The reminder is linked to the first notification.
+func (r *repository) EnqueueReminderTx(ctx context.Context, rem *model.Reminder, notifications []*model.Notification) (bool, error) {
+	tx, err := r.db.Master.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO event_reminders (registration_id, start_time, offset_seconds)
+		VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING
+	`, rem.RegistrationID, rem.StartTime, int(rem.Offset.Seconds()))
+	if err != nil {
+		_ = tx.Rollback()
+		return false, fmt.Errorf("failed to record reminder: %w", err)
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		_ = tx.Rollback()
+		return false, nil
+	}
+
+	var firstID int64
+	for _, n := range notifications {
+		id, err := enqueueNotification(ctx, tx, n)
+		if err != nil {
+			_ = tx.Rollback()
+			return false, err
+		}
+		if firstID == 0 {
+			firstID = id
+		}
+	}
+	if firstID != 0 {
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE event_reminders SET notification_id = $1
+			WHERE registration_id = $2 AND start_time = $3 AND offset_seconds = $4
+		`, firstID, rem.RegistrationID, rem.StartTime, int(rem.Offset.Seconds())); err != nil {
+			_ = tx.Rollback()
+			return false, fmt.Errorf("failed to link reminder notification: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit reminder: %w", err)
+	}
 	return true, nil
 }
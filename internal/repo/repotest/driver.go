@@ -0,0 +1,226 @@
+// Package repotest is a database/sql driver that answers the event list queries of the repository
+// from memory and counts every query, so tests can check how many queries a request costs
+// without a Postgres server.
+package repotest
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/wb-go/wbf/dbpg"
+
+	"fifthOne/internal/model"
+)
+
+// RegistrationsPerEvent is how many registrations every event has.
+const RegistrationsPerEvent = 3
+
+// Organizer owns the generated events.
+const Organizer = "organizer-1"
+
+// Driver answers COUNT(*) with Events, the event list with Events generated events or with
+// Fixture, and the registrations of events with RegistrationsPerEvent confirmed ones each.
+// Any other query fails.
+type Driver struct {
+	Events int
+	// Fixture, if set, replaces the generated events and is paged by the keyset condition of the
+	// query the way Postgres would.
+	Fixture []model.Event
+
+	queries atomic.Int64
+}
+
+// Open returns a database on d; the caller closes it.
+func Open(d *Driver) *dbpg.DB {
+	return &dbpg.DB{Master: sql.OpenDB(d)}
+}
+
+// Queries is the number of queries run so far.
+func (d *Driver) Queries() int64 {
+	return d.queries.Load()
+}
+
+func (d *Driver) Connect(context.Context) (driver.Conn, error) { return &conn{d}, nil }
+func (d *Driver) Driver() driver.Driver                        { return d }
+func (d *Driver) Open(string) (driver.Conn, error)             { return &conn{d}, nil }
+
+type conn struct{ d *Driver }
+
+var errUnsupported = errors.New("repotest driver: not supported")
+
+func (c *conn) Prepare(string) (driver.Stmt, error) { return nil, errUnsupported }
+func (c *conn) Close() error                        { return nil }
+func (c *conn) Begin() (driver.Tx, error)           { return nil, errUnsupported }
+func (c *conn) Ping(context.Context) error          { return nil }
+
+func (c *conn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.d.queries.Add(1)
+
+	switch {
+	case strings.HasPrefix(query, "SELECT COUNT(*)"):
+		return &rows{cols: 1, rows: [][]driver.Value{{int64(c.d.Events)}}}, nil
+	case strings.Contains(query, "FROM events") && c.d.Fixture != nil:
+		return &rows{cols: 19, rows: fixturePage(c.d.Fixture, query, args)}, nil
+	case strings.Contains(query, "FROM events"):
+		limit := int(args[len(args)-1].Value.(int64))
+		return &rows{cols: 19, rows: generatedEvents(min(c.d.Events, limit))}, nil
+	case strings.Contains(query, "FROM registrations reg"):
+		ids, err := parseIntArray(args[0].Value)
+		if err != nil {
+			return nil, err
+		}
+		return &rows{cols: 19, rows: registrations(ids)}, nil
+	}
+	return nil, fmt.Errorf("repotest driver: unexpected query %q", query)
+}
+
+func generatedEvents(n int) [][]driver.Value {
+	now := time.Now()
+	out := make([][]driver.Value, 0, n)
+	for i := 1; i <= n; i++ {
+		out = append(out, eventRow(model.Event{
+			ID:          i,
+			Name:        "Event " + strconv.Itoa(i),
+			StartTime:   now,
+			OrganizerID: Organizer,
+			CreatedAt:   now,
+		}))
+	}
+	return out
+}
+
+func eventRow(e model.Event) []driver.Value {
+	return []driver.Value{
+		int64(e.ID), e.Name, "", e.StartTime, e.StartTime.Add(time.Hour), "Москва",
+		int64(10), int64(15), int64(0), e.OrganizerID, int64(0), "RUB",
+		"full", int64(0), int64(0), e.CreatedAt, e.CreatedAt,
+		int64(RegistrationsPerEvent), int64(0), // seats.taken, seats.waitlisted
+	}
+}
+
+var orderByRegexp = regexp.MustCompile(`ORDER BY (\w+) (ASC|DESC)`)
+
+// fixturePage answers a ListEvents query from events: ordered by the sort column and the ID, after
+// the "(column, id) > (value, id)" cursor if there is one, up to the limit.
+func fixturePage(events []model.Event, query string, args []driver.NamedValue) [][]driver.Value {
+	order := orderByRegexp.FindStringSubmatch(query)
+	column, desc := order[1], order[2] == "DESC"
+	key := func(e model.Event) any {
+		switch column {
+		case "start_time":
+			return e.StartTime
+		case "created_at":
+			return e.CreatedAt
+		}
+		return e.Name
+	}
+	// compare orders a before b as the query does: by column, then ID
+	compare := func(aKey any, aID int64, bKey any, bID int64) int {
+		var c int
+		switch a := aKey.(type) {
+		case time.Time:
+			c = a.Compare(bKey.(time.Time))
+		case string:
+			c = strings.Compare(a, bKey.(string))
+		}
+		if c == 0 {
+			c = cmp.Compare(aID, bID)
+		}
+		if desc {
+			c = -c
+		}
+		return c
+	}
+
+	sorted := slices.Clone(events)
+	slices.SortFunc(sorted, func(a, b model.Event) int {
+		return compare(key(a), int64(a.ID), key(b), int64(b.ID))
+	})
+
+	limit := int(args[len(args)-1].Value.(int64))
+	if strings.Contains(query, "("+column+", id)") {
+		after, afterID := args[len(args)-3].Value, args[len(args)-2].Value.(int64)
+		sorted = slices.DeleteFunc(sorted, func(e model.Event) bool {
+			return compare(key(e), int64(e.ID), after, afterID) <= 0
+		})
+	}
+
+	out := make([][]driver.Value, 0, limit)
+	for _, e := range sorted[:min(limit, len(sorted))] {
+		out = append(out, eventRow(e))
+	}
+	return out
+}
+
+func registrations(eventIDs []int64) [][]driver.Value {
+	now := time.Now()
+	out := make([][]driver.Value, 0, len(eventIDs)*RegistrationsPerEvent)
+	for _, eventID := range eventIDs {
+		for j := range RegistrationsPerEvent {
+			id := eventID*RegistrationsPerEvent + int64(j)
+			out = append(out, []driver.Value{
+				id, eventID, "Attendee", fmt.Sprintf("a%d@example.com", id), "", "confirmed",
+				now, now, nil, int64(0), "email",
+				int64(0),                          // waitlist_position
+				nil, nil, nil, nil, nil, nil, nil, // no refund
+			})
+		}
+	}
+	return out
+}
+
+// parseIntArray reads a pq.Array of int64 as the driver receives it, e.g. "{1,2,3}".
+func parseIntArray(v driver.Value) ([]int64, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("repotest driver: unexpected array argument %T", v)
+	}
+	s = strings.Trim(s, "{}")
+	if s == "" {
+		return nil, nil
+	}
+	var ids []int64
+	for _, part := range strings.Split(s, ",") {
+		id, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+type rows struct {
+	cols int
+	rows [][]driver.Value
+}
+
+func (r *rows) Columns() []string {
+	cols := make([]string, r.cols)
+	for i := range cols {
+		cols[i] = "c" + strconv.Itoa(i)
+	}
+	return cols
+}
+
+func (r *rows) Close() error { return nil }
+
+func (r *rows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
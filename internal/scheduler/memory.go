@@ -0,0 +1,126 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	defaultWheelTick  = time.Second
+	defaultWheelSlots = 512
+	memoryRetryDelay  = 5 * time.Second
+)
+
+// Memory is a hashed timer wheel kept in process memory. It is meant for development and tests:
+// scheduled expiries are lost on restart, and only the process that calls Run sees them.
+type Memory struct {
+	log  *zerolog.Logger
+	tick time.Duration
+
+	mu    sync.Mutex
+	slots []map[int64]*wheelTimer
+	cur   int
+	timer map[int64]*wheelTimer
+}
+
+type wheelTimer struct {
+	expiry Expiry
+	slot   int
+	// rounds is how many more full turns of the wheel pass before the timer fires.
+	rounds int
+}
+
+func NewMemory(log *zerolog.Logger) *Memory {
+	slots := make([]map[int64]*wheelTimer, defaultWheelSlots)
+	for i := range slots {
+		slots[i] = make(map[int64]*wheelTimer)
+	}
+	return &Memory{
+		log:   log,
+		tick:  defaultWheelTick,
+		slots: slots,
+		timer: make(map[int64]*wheelTimer),
+	}
+}
+
+func (s *Memory) ScheduleExpiry(_ context.Context, e Expiry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t, ok := s.timer[e.RegistrationID]; ok && t.expiry.Generation > e.Generation {
+		return nil
+	}
+	s.removeLocked(e.RegistrationID)
+
+	// round up so a timer never fires early; it always waits at least one tick
+	ticks := max(int((time.Until(e.At)+s.tick-1)/s.tick), 1)
+	t := &wheelTimer{
+		expiry: e,
+		slot:   (s.cur + ticks) % len(s.slots),
+		rounds: (ticks - 1) / len(s.slots),
+	}
+	s.slots[t.slot][e.RegistrationID] = t
+	s.timer[e.RegistrationID] = t
+	return nil
+}
+
+func (s *Memory) Cancel(_ context.Context, registrationID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.removeLocked(registrationID)
+	return nil
+}
+
+func (s *Memory) Run(ctx context.Context, handler Handler) error {
+	ticker := time.NewTicker(s.tick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		// due timers are already off the wheel, so they are handled even if ctx is canceled meanwhile
+		handlerCtx := context.WithoutCancel(ctx)
+		for _, e := range s.advance() {
+			if err := handler(handlerCtx, e); err != nil {
+				s.log.Warn().Err(err).Int64("registration_id", e.RegistrationID).Msg("Expiry handler failed, will retry")
+				e.At = time.Now().Add(memoryRetryDelay)
+				_ = s.ScheduleExpiry(ctx, e)
+			}
+		}
+	}
+}
+
+// advance moves the wheel one slot forward and returns the registrations that are now due.
+func (s *Memory) advance() []Expiry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cur = (s.cur + 1) % len(s.slots)
+
+	var due []Expiry
+	for id, t := range s.slots[s.cur] {
+		if t.rounds > 0 {
+			t.rounds--
+			continue
+		}
+		due = append(due, t.expiry)
+		delete(s.slots[s.cur], id)
+		delete(s.timer, id)
+	}
+	return due
+}
+
+func (s *Memory) removeLocked(registrationID int64) {
+	if t, ok := s.timer[registrationID]; ok {
+		delete(s.slots[t.slot], registrationID)
+		delete(s.timer, registrationID)
+	}
+}
@@ -0,0 +1,125 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/wb-go/wbf/dbpg"
+)
+
+const (
+	defaultPollInterval = time.Second
+	defaultPollBatch    = 50
+	// pollLease hides a claimed expiry from other pollers while its handler runs; a failed
+	// expiry becomes due again once the lease runs out.
+	pollLease = time.Minute
+)
+
+// Postgres keeps scheduled expiries in the scheduled_expiries table and polls it. Several
+// pollers may run at once: due rows are claimed with FOR UPDATE SKIP LOCKED.
+type Postgres struct {
+	db       *dbpg.DB
+	log      *zerolog.Logger
+	interval time.Duration
+	batch    int
+}
+
+func NewPostgres(db *dbpg.DB, log *zerolog.Logger) *Postgres {
+	return &Postgres{
+		db:       db,
+		log:      log,
+		interval: defaultPollInterval,
+		batch:    defaultPollBatch,
+	}
+}
+
+func (s *Postgres) ScheduleExpiry(ctx context.Context, e Expiry) error {
+	// an outbox message delivered late must not overwrite the expiry of a newer generation
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO scheduled_expiries (registration_id, generation, run_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (registration_id) DO UPDATE
+		SET generation = EXCLUDED.generation, run_at = EXCLUDED.run_at, locked_until = NULL
+		WHERE scheduled_expiries.generation <= EXCLUDED.generation
+	`, e.RegistrationID, e.Generation, e.At); err != nil {
+		return fmt.Errorf("failed to schedule expiry: %w", err)
+	}
+	return nil
+}
+
+func (s *Postgres) Cancel(ctx context.Context, registrationID int64) error {
+	if _, err := s.db.ExecContext(ctx,
+		`DELETE FROM scheduled_expiries WHERE registration_id = $1`, registrationID); err != nil {
+		return fmt.Errorf("failed to cancel expiry: %w", err)
+	}
+	return nil
+}
+
+func (s *Postgres) Run(ctx context.Context, handler Handler) error {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.pollOnce(ctx, handler)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Postgres) pollOnce(ctx context.Context, handler Handler) {
+	rows, err := s.db.Master.QueryContext(ctx, `
+		UPDATE scheduled_expiries
+		SET locked_until = NOW() + $2::int * INTERVAL '1 second'
+		WHERE registration_id IN (
+			SELECT registration_id FROM scheduled_expiries
+			WHERE run_at <= NOW() AND (locked_until IS NULL OR locked_until <= NOW())
+			ORDER BY run_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING registration_id, generation, run_at
+	`, s.batch, int64(pollLease.Seconds()))
+	if err != nil {
+		if ctx.Err() == nil {
+			s.log.Error().Err(err).Msg("Failed to claim due expiries")
+		}
+		return
+	}
+
+	var due []Expiry
+	for rows.Next() {
+		var d Expiry
+		if err := rows.Scan(&d.RegistrationID, &d.Generation, &d.At); err != nil {
+			s.log.Error().Err(err).Msg("Failed to scan due expiry")
+			continue
+		}
+		due = append(due, d)
+	}
+	_ = rows.Close()
+
+	// an expiry that has started is finished even during shutdown; the rest of the batch is left
+	// to the next poll once its lease runs out
+	handlerCtx := context.WithoutCancel(ctx)
+	for _, d := range due {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := handler(handlerCtx, d); err != nil {
+			s.log.Warn().Err(err).Int64("registration_id", d.RegistrationID).Msg("Expiry handler failed, will retry")
+			continue
+		}
+		// generation and run_at are compared so an expiry rescheduled in the meantime is kept
+		if _, err := s.db.ExecContext(handlerCtx, `
+			DELETE FROM scheduled_expiries
+			WHERE registration_id = $1 AND generation = $2 AND run_at = $3
+		`, d.RegistrationID, d.Generation, d.At); err != nil {
+			s.log.Error().Err(err).Int64("registration_id", d.RegistrationID).Msg("Failed to remove handled expiry")
+		}
+	}
+}
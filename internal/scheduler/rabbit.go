@@ -0,0 +1,76 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"fifthOne/internal/dto"
+	"fifthOne/internal/rabbit"
+
+	"github.com/rs/zerolog"
+)
+
+// Rabbit schedules expiries as messages on the x-delayed-message exchange. A published message
+// cannot be withdrawn, so Cancel and rescheduling leave the old message in place, and the handler
+// filters out stale expiries by status and generation. An expiry further away than rabbit.MaxDelay
+// is published again each time it arrives early, until its time has come.
+type Rabbit struct {
+	rmq *rabbit.Client
+	log *zerolog.Logger
+}
+
+func NewRabbit(rmq *rabbit.Client, log *zerolog.Logger) *Rabbit {
+	return &Rabbit{rmq: rmq, log: log}
+}
+
+func (s *Rabbit) ScheduleExpiry(ctx context.Context, e Expiry) error {
+	payload, err := json.Marshal(dto.RegistrationOperateMessage{
+		RegistrationID: e.RegistrationID,
+		ExpireAt:       e.At,
+		Generation:     e.Generation,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal expiry message: %w", err)
+	}
+	return s.rmq.PublishConfirmed(ctx, payload, max(time.Until(e.At), 0))
+}
+
+// Healthy reports whether the broker connection is up.
+func (s *Rabbit) Healthy() bool {
+	return s.rmq.Healthy()
+}
+
+// Client exposes the underlying connection, e.g. for dead-letter administration.
+func (s *Rabbit) Client() *rabbit.Client {
+	return s.rmq
+}
+
+func (s *Rabbit) Cancel(context.Context, int64) error {
+	return nil
+}
+
+// Run consumes until ctx is done and returns once the in-flight handlers have finished.
+func (s *Rabbit) Run(ctx context.Context, handler Handler) error {
+	// handlers keep running after ctx is canceled so that they can finish and ack
+	handlerCtx := context.WithoutCancel(ctx)
+	return s.rmq.Consume(ctx, func(body []byte) error {
+		var msg dto.RegistrationOperateMessage
+		if err := json.Unmarshal(body, &msg); err != nil {
+			// a malformed message will never parse, so it is dead-lettered right away
+			s.log.Error().Err(err).Msgf("Failed to unmarshal expiry message: %s", string(body))
+			return rabbit.Permanent(err)
+		}
+		e := Expiry{
+			RegistrationID: msg.RegistrationID,
+			Generation:     msg.Generation,
+			At:             msg.ExpireAt,
+		}
+		if time.Until(e.At) > 0 {
+			// the delay was longer than rabbit.MaxDelay, so wait for the rest of it
+			return s.ScheduleExpiry(handlerCtx, e)
+		}
+		return handler(handlerCtx, e)
+	})
+}
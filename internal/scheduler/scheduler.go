@@ -0,0 +1,38 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	KindRabbit   = "rabbit"
+	KindPostgres = "postgres"
+	KindMemory   = "memory"
+)
+
+// Expiry is the expiry of one pending registration. Generation is the registration's
+// expiry_generation when the expiry was scheduled; extending the deadline bumps it, which makes
+// every expiry scheduled before stale.
+type Expiry struct {
+	RegistrationID int64
+	Generation     int64
+	At             time.Time
+}
+
+// Handler is called once an expiry is due. It must be idempotent: every implementation delivers
+// at least once, and Cancel is best effort, so the handler may see registrations that are no
+// longer pending or expiries of an older generation.
+type Handler func(ctx context.Context, e Expiry) error
+
+// Scheduler delays the expiry of pending registrations until their payment timeout runs out.
+// Expiries are addressed by registration ID.
+type Scheduler interface {
+	// ScheduleExpiry arranges for the handler to be called with e at e.At. Scheduling the same
+	// registration again replaces the earlier expiry where supported.
+	ScheduleExpiry(ctx context.Context, e Expiry) error
+	// Cancel drops a scheduled expiry, e.g. once the registration is confirmed.
+	Cancel(ctx context.Context, registrationID int64) error
+	// Run delivers due expiries to handler until ctx is done.
+	Run(ctx context.Context, handler Handler) error
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"errors"
+	"strconv"
+
+	"fifthOne/internal/dto"
+	"fifthOne/internal/rabbit"
+
+	"github.com/wb-go/wbf/ginext"
+)
+
+const defaultDeadLetterLimit = 50
+
+func (s *service) requireDeadLetters(ctx *ginext.Context) bool {
+	if s.deadLetters == nil {
+		dto.ServiceUnavailableError(ctx, "Dead-letter queue is only available with the rabbit scheduler")
+		return false
+	}
+	return true
+}
+
+func (s *service) deadLetterError(ctx *ginext.Context, err error) {
+	switch {
+	case errors.Is(err, rabbit.ErrDeadLetterNotFound):
+		dto.DeadLetterNotFoundError(ctx)
+	case errors.Is(err, rabbit.ErrNotConnected):
+		dto.ServiceUnavailableError(ctx, "RabbitMQ is not connected")
+	default:
+		s.log.Error().Err(err).Msg("dead-letter operation failed")
+		dto.InternalServerError(ctx)
+	}
+}
+
+func (s *service) ListDeadLetters(ctx *ginext.Context) {
+	if !s.requireDeadLetters(ctx) {
+		return
+	}
+
+	limit := defaultDeadLetterLimit
+	if raw := ctx.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			dto.FieldIncorrectError(ctx, "limit")
+			return
+		}
+		limit = n
+	}
+
+	letters, err := s.deadLetters.ListDeadLetters(limit)
+	if err != nil {
+		s.deadLetterError(ctx, err)
+		return
+	}
+	dto.SuccessResponse(ctx, letters)
+}
+
+func (s *service) GetDeadLetter(ctx *ginext.Context) {
+	if !s.requireDeadLetters(ctx) {
+		return
+	}
+
+	letter, err := s.deadLetters.GetDeadLetter(ctx.Param("id"))
+	if err != nil {
+		s.deadLetterError(ctx, err)
+		return
+	}
+	dto.SuccessResponse(ctx, letter)
+}
+
+// ReplayDeadLetter puts a dead letter back on the main queue with a fresh retry budget.
+func (s *service) ReplayDeadLetter(ctx *ginext.Context) {
+	if !s.requireDeadLetters(ctx) {
+		return
+	}
+
+	id := ctx.Param("id")
+	if err := s.deadLetters.ReplayDeadLetter(ctx.Request.Context(), id); err != nil {
+		s.deadLetterError(ctx, err)
+		return
+	}
+	s.log.Info().Str("message_id", id).Msg("dead letter replayed")
+	dto.SuccessResponse(ctx, nil)
+}
+
+func (s *service) DeleteDeadLetter(ctx *ginext.Context) {
+	if !s.requireDeadLetters(ctx) {
+		return
+	}
+
+	id := ctx.Param("id")
+	if err := s.deadLetters.DeleteDeadLetter(id); err != nil {
+		s.deadLetterError(ctx, err)
+		return
+	}
+	s.log.Info().Str("message_id", id).Msg("dead letter deleted")
+	dto.SuccessResponse(ctx, nil)
+}
+
+func (s *service) PurgeDeadLetters(ctx *ginext.Context) {
+	if !s.requireDeadLetters(ctx) {
+		return
+	}
+
+	n, err := s.deadLetters.PurgeDeadLetters()
+	if err != nil {
+		s.deadLetterError(ctx, err)
+		return
+	}
+	s.log.Info().Int("count", n).Msg("dead-letter queue purged")
+	dto.SuccessResponse(ctx, map[string]int{"purged": n})
+}
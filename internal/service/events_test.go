@@ -0,0 +1,126 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"fifthOne/internal/auth"
+	"fifthOne/internal/dto"
+	"fifthOne/internal/repo"
+	"fifthOne/internal/repo/repotest"
+
+	"github.com/rs/zerolog"
+	"github.com/wb-go/wbf/ginext"
+)
+
+// The event list must cost the same number of queries however many events are on the page: seat
+// counts come with the events and the admin view loads all registrations at once.
+
+const maxEventPageSize = 100
+
+var listCallers = []struct {
+	name      string
+	principal auth.Principal
+	query     string
+	// registrations is how many registrations each event of the answer shows
+	registrations int
+}{
+	{"attendee", auth.Anonymous, "", 0},
+	{"admin", auth.Principal{Subject: "admin", Role: auth.RoleAdmin}, "&admin=true", repotest.RegistrationsPerEvent},
+	{"organizer", auth.Principal{Subject: repotest.Organizer, Role: auth.RoleOrganizer}, "&admin=true", repotest.RegistrationsPerEvent},
+}
+
+// newEventListRouter serves GetAllEvents to principal from a database with events events.
+func newEventListRouter(tb testing.TB, principal auth.Principal, events int) (*ginext.Engine, *repotest.Driver) {
+	tb.Helper()
+	driver := &repotest.Driver{Events: events}
+	db := repotest.Open(driver)
+	tb.Cleanup(func() { _ = db.Master.Close() })
+
+	log := zerolog.Nop()
+	r, err := repo.NewRepository(db, &log, nil)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	s := NewService(r, &log, nil, nil, nil, nil, "http://localhost")
+
+	router := ginext.New("release")
+	router.GET("/v1/events", func(c *ginext.Context) {
+		auth.SetPrincipal(c, principal)
+		c.Next()
+	}, s.GetAllEvents)
+	return router, driver
+}
+
+// listEvents requests a page of limit events and checks the answer.
+func listEvents(tb testing.TB, router *ginext.Engine, query string, limit, registrations int) {
+	tb.Helper()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/v1/events?limit=%d%s", limit, query), nil))
+	if w.Code != http.StatusOK {
+		tb.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+
+	var resp struct {
+		Data []dto.EventInfoResponse `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		tb.Fatal(err)
+	}
+	if len(resp.Data) != limit {
+		tb.Fatalf("got %d events, want %d", len(resp.Data), limit)
+	}
+	for _, e := range resp.Data {
+		if len(e.Registrations) != registrations {
+			tb.Fatalf("event %d: got %d registrations, want %d", e.ID, len(e.Registrations), registrations)
+		}
+	}
+}
+
+// countQueries is how many queries one request for a page of limit events costs.
+func countQueries(tb testing.TB, principal auth.Principal, query string, limit, registrations int) int64 {
+	tb.Helper()
+	router, driver := newEventListRouter(tb, principal, limit)
+	listEvents(tb, router, query, limit, registrations)
+	return driver.Queries()
+}
+
+func TestGetAllEventsQueryCount(t *testing.T) {
+	for _, caller := range listCallers {
+		t.Run(caller.name, func(t *testing.T) {
+			one := countQueries(t, caller.principal, caller.query, 1, caller.registrations)
+			for _, limit := range []int{2, 20, maxEventPageSize} {
+				if got := countQueries(t, caller.principal, caller.query, limit, caller.registrations); got != one {
+					t.Errorf("%d queries for a page of %d events, %d for 1", got, limit, one)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkGetAllEvents(b *testing.B) {
+	for _, caller := range listCallers {
+		want := countQueries(b, caller.principal, caller.query, 1, caller.registrations)
+		for _, limit := range []int{1, maxEventPageSize} {
+			b.Run(fmt.Sprintf("%s/events=%d", caller.name, limit), func(b *testing.B) {
+				router, driver := newEventListRouter(b, caller.principal, limit)
+
+				b.ReportAllocs()
+				b.ResetTimer()
+				for range b.N {
+					listEvents(b, router, caller.query, limit, caller.registrations)
+				}
+				b.StopTimer()
+
+				perOp := float64(driver.Queries()) / float64(b.N)
+				b.ReportMetric(perOp, "queries/op")
+				if perOp != float64(want) {
+					b.Fatalf("%.1f queries per page of %d events, want %d as for 1 event", perOp, limit, want)
+				}
+			})
+		}
+	}
+}
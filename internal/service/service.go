@@ -1,40 +1,75 @@
 package service
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
+	"fifthOne/internal/auth"
 	"fifthOne/internal/dto"
-	"fifthOne/internal/mailer"
 	"fifthOne/internal/model"
+	"fifthOne/internal/notify"
+	"fifthOne/internal/payment"
 	"fifthOne/internal/rabbit"
 	"fifthOne/internal/repo"
+	"fifthOne/internal/scheduler"
 	"fifthOne/pkg/validator"
 	"fmt"
 	"github.com/rs/zerolog"
 	"github.com/wb-go/wbf/ginext"
-	"github.com/wb-go/wbf/zlog"
+	"io"
+	"net/http"
 	"strconv"
+	"strings"
 	"time"
 )
 
+const (
+	defaultCurrency      = "RUB"
+	defaultEventPageSize = 20
+)
+
 type Service interface {
 	CreateEvent(ctx *ginext.Context)
+	UpdateEvent(ctx *ginext.Context)
 	Book(ctx *ginext.Context)
 	Confirm(ctx *ginext.Context)
 	GetInfo(ctx *ginext.Context)
 	GetAllEvents(ctx *ginext.Context)
+	CancelRegistration(ctx *ginext.Context)
+	GetRegistrationHistory(ctx *ginext.Context)
+	Checkout(ctx *ginext.Context)
+	PaymentWebhook(ctx *ginext.Context)
+	CancelRegistrationByAdmin(ctx *ginext.Context)
+	ExtendRegistration(ctx *ginext.Context)
+	UnsubscribePage(ctx *ginext.Context)
+	Unsubscribe(ctx *ginext.Context)
+	ListDeadLetters(ctx *ginext.Context)
+	GetDeadLetter(ctx *ginext.Context)
+	ReplayDeadLetter(ctx *ginext.Context)
+	DeleteDeadLetter(ctx *ginext.Context)
+	PurgeDeadLetters(ctx *ginext.Context)
 }
 
 type service struct {
-	repo repo.Repository
-	log  *zerolog.Logger
-	rbt  *rabbit.Client
+	repo     repo.Repository
+	log      *zerolog.Logger
+	payments payment.Provider
+	sched    scheduler.Scheduler
+	// unsubscribe checks the signed unsubscribe links in messages.
+	unsubscribe *notify.Unsubscribe
+	// deadLetters is nil unless expiries go through RabbitMQ.
+	deadLetters *rabbit.Client
+	publicURL   string
 }
 
-func NewService(repo repo.Repository, logger *zerolog.Logger, rbt *rabbit.Client) Service {
+func NewService(repo repo.Repository, logger *zerolog.Logger, payments payment.Provider, sched scheduler.Scheduler, unsubscribe *notify.Unsubscribe, deadLetters *rabbit.Client, publicURL string) Service {
 	return &service{
-		repo: repo,
-		log:  logger,
-		rbt:  rbt,
+		repo:        repo,
+		log:         logger,
+		payments:    payments,
+		sched:       sched,
+		unsubscribe: unsubscribe,
+		deadLetters: deadLetters,
+		publicURL:   publicURL,
 	}
 }
 
@@ -52,16 +87,32 @@ func (s *service) CreateEvent(ctx *ginext.Context) {
 		return
 	}
 
+	currency := strings.ToUpper(req.Currency)
+	if currency == "" {
+		currency = defaultCurrency
+	}
+	refundPolicy := model.RefundPolicyType(req.RefundPolicy)
+	if refundPolicy == "" {
+		refundPolicy = model.RefundFull
+	}
+
 	event := &model.Event{
-		Name:                  req.Name,
-		Description:           req.Description,
-		StartTime:             req.StartTime,
-		EndTime:               req.EndTime,
-		Location:              req.Location,
-		Capacity:              req.Capacity,
-		PaymentTimeoutMinutes: req.PaymentTimeoutMinutes,
-		CreatedAt:             time.Now(),
-		UpdatedAt:             time.Now(),
+		Name:                      req.Name,
+		Description:               req.Description,
+		StartTime:                 req.StartTime,
+		EndTime:                   req.EndTime,
+		Location:                  req.Location,
+		Capacity:                  req.Capacity,
+		PaymentTimeoutMinutes:     req.PaymentTimeoutMinutes,
+		CancellationDeadlineHours: req.CancellationDeadlineHours,
+		OrganizerID:               auth.FromContext(ctx).Subject,
+		Price:                     req.Price,
+		Currency:                  currency,
+		RefundPolicy:              refundPolicy,
+		RefundFullHours:           req.RefundFullHours,
+		RefundPartialPercent:      req.RefundPartialPercent,
+		CreatedAt:                 time.Now(),
+		UpdatedAt:                 time.Now(),
 	}
 
 	id, err := s.repo.CreateEvent(ctx, event)
@@ -75,15 +126,111 @@ func (s *service) CreateEvent(ctx *ginext.Context) {
 	s.log.Info().Int64("event_id", id).Msg("event created successfully")
 
 	dto.SuccessCreatedResponse(ctx, dto.EventResponse{
-		ID:                    int64(event.ID),
-		Name:                  event.Name,
-		Description:           event.Description,
-		StartTime:             event.StartTime,
-		EndTime:               event.EndTime,
-		Location:              event.Location,
-		Capacity:              event.Capacity,
-		PaymentTimeoutMinutes: event.PaymentTimeoutMinutes,
-		CreatedAt:             event.CreatedAt,
+		ID:                        int64(event.ID),
+		Name:                      event.Name,
+		Description:               event.Description,
+		StartTime:                 event.StartTime,
+		EndTime:                   event.EndTime,
+		Location:                  event.Location,
+		Capacity:                  event.Capacity,
+		PaymentTimeoutMinutes:     event.PaymentTimeoutMinutes,
+		CancellationDeadlineHours: event.CancellationDeadlineHours,
+		OrganizerID:               event.OrganizerID,
+		Price:                     event.Price,
+		Currency:                  event.Currency,
+		RefundPolicy:              string(event.RefundPolicy),
+		RefundFullHours:           event.RefundFullHours,
+		RefundPartialPercent:      event.RefundPartialPercent,
+		CreatedAt:                 event.CreatedAt,
+	})
+}
+
+// UpdateEvent changes an event. A new start time also moves the reminders that have not gone out
+// yet, and reminders already sent for the old time are sent again for the new one.
+func (s *service) UpdateEvent(ctx *ginext.Context) {
+	eventID, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		dto.BadResponseError(ctx, dto.FieldIncorrect, "Invalid event ID")
+		return
+	}
+
+	var req dto.UpdateEventRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		dto.BadResponseError(ctx, dto.FieldIncorrect, "Invalid JSON format")
+		return
+	}
+
+	if verr := validator.Validate(ctx, req); verr != nil {
+		dto.BadResponseError(ctx, dto.FieldIncorrect, fmt.Sprintf("%v", verr))
+		return
+	}
+
+	existing, err := s.repo.GetEventByID(ctx, eventID)
+	if err != nil {
+		dto.EventNotFoundError(ctx)
+		return
+	}
+	if !auth.FromContext(ctx).CanManage(existing.OrganizerID) {
+		dto.ForbiddenError(ctx)
+		return
+	}
+
+	start, end := existing.StartTime, existing.EndTime
+	if req.StartTime != nil {
+		if !req.StartTime.After(time.Now()) {
+			dto.BadResponseError(ctx, dto.FieldIncorrect, "start_time must be in the future")
+			return
+		}
+		start = *req.StartTime
+	}
+	if req.EndTime != nil {
+		end = *req.EndTime
+	}
+	if !end.IsZero() && end.Before(start) {
+		dto.BadResponseError(ctx, dto.FieldIncorrect, "end_time must not be before start_time")
+		return
+	}
+
+	event, err := s.repo.UpdateEvent(ctx.Request.Context(), eventID, model.EventUpdate{
+		Name:        req.Name,
+		Description: req.Description,
+		Location:    req.Location,
+		StartTime:   req.StartTime,
+		EndTime:     req.EndTime,
+	})
+	if err != nil {
+		if errors.Is(err, repo.ErrEventNotFound) {
+			dto.EventNotFoundError(ctx)
+			return
+		}
+		s.log.Error().Err(err).Int64("event_id", eventID).Msg("failed to update event")
+		dto.InternalServerError(ctx)
+		return
+	}
+
+	log := s.log.Info().Int64("event_id", eventID).Str("subject", auth.FromContext(ctx).Subject)
+	if !event.StartTime.Equal(existing.StartTime) {
+		log = log.Time("old_start_time", existing.StartTime).Time("start_time", event.StartTime)
+	}
+	log.Msg("event updated")
+
+	dto.SuccessResponse(ctx, dto.EventResponse{
+		ID:                        int64(event.ID),
+		Name:                      event.Name,
+		Description:               event.Description,
+		StartTime:                 event.StartTime,
+		EndTime:                   event.EndTime,
+		Location:                  event.Location,
+		Capacity:                  event.Capacity,
+		PaymentTimeoutMinutes:     event.PaymentTimeoutMinutes,
+		CancellationDeadlineHours: event.CancellationDeadlineHours,
+		OrganizerID:               event.OrganizerID,
+		Price:                     event.Price,
+		Currency:                  event.Currency,
+		RefundPolicy:              string(event.RefundPolicy),
+		RefundFullHours:           event.RefundFullHours,
+		RefundPartialPercent:      event.RefundPartialPercent,
+		CreatedAt:                 event.CreatedAt,
 	})
 }
 
@@ -105,23 +252,31 @@ func (s *service) Book(ctx *ginext.Context) {
 		return
 	}
 
+	phone, err := validator.NormalizePhone(req.Phone)
+	if err != nil {
+		dto.FieldBadFormatError(ctx, "phone")
+		return
+	}
+	notifyChannel := model.NotifyChannel(req.NotifyChannel)
+	if notifyChannel == "" {
+		notifyChannel = model.NotifyEmail
+	}
+
 	registration := &model.Registration{
-		EventID:  int(eventID),
-		FullName: req.FullName,
-		Email:    req.Email,
-		Phone:    req.Phone,
-		Status:   "pending",
+		EventID:       int(eventID),
+		FullName:      req.FullName,
+		Email:         req.Email,
+		Phone:         phone,
+		Status:        model.StatusPending,
+		NotifyChannel: notifyChannel,
 	}
 
-	id, timeout, err := s.repo.BookRegistrationTx(ctx.Request.Context(), registration)
+	id, event, err := s.repo.BookRegistrationTx(ctx.Request.Context(), registration)
 	if err != nil {
 		switch err {
 		case repo.ErrEventNotFound:
 			dto.EventNotFoundError(ctx)
 			return
-		case repo.ErrEventFull:
-			dto.BadResponseError(ctx, dto.FieldIncorrect, "Event is full")
-			return
 		case repo.ErrDuplicateRegistration:
 			dto.RegistrationDuplicateError(ctx)
 			return
@@ -132,46 +287,33 @@ func (s *service) Book(ctx *ginext.Context) {
 		}
 	}
 
-	s.log.Info().Int64("registration_id", id).Msg("registration created successfully")
-
-	msg := dto.RegistrationOperateMessage{
-		RegistrationID: id,
-		EventID:        eventID,
-		ExpireAt:       time.Now().Add(time.Duration(timeout) * time.Minute),
-	}
-	payload, err := json.Marshal(msg)
-	if err != nil {
-		s.log.Error().Err(err).Msg("failed to marshal cancel message")
-		dto.InternalServerError(ctx)
-		return
-	}
-	delaySeconds := timeout * 60
-	if err := s.rbt.Publish(payload, delaySeconds); err != nil {
-		s.log.Error().Err(err).Msg("failed to publish cancel message to RabbitMQ")
-	}
-
-	event, err := s.repo.GetEventByID(ctx, int64(registration.EventID))
-	if err != nil {
-		zlog.Logger.Error().Err(err).Msg("Failed to get event from DB in worker")
-	}
-
-	if err := mailer.SendRegistrationEmail(
-		&zlog.Logger,
-		event.Name,
-		"pending",
-		registration.Email,
-		event.PaymentTimeoutMinutes,
-	); err != nil {
-		zlog.Logger.Warn().Err(err).Msg("Failed to send notification on e-mail")
+	s.log.Info().
+		Int64("registration_id", id).
+		Str("status", string(registration.Status)).
+		Msg("registration created successfully")
+
+	confirmURL, paymentURL := "", ""
+	if registration.ConfirmationToken != "" {
+		confirmURL = notify.ConfirmationLink(s.publicURL, eventID, registration.ConfirmationToken)
+		if event.IsPaid() {
+			paymentURL = notify.CheckoutLink(s.publicURL, eventID, registration.ConfirmationToken)
+		}
 	}
 
 	dto.SuccessCreatedResponse(ctx, dto.RegistrationResponse{
-		ID:        id,
-		EventID:   eventID,
-		FullName:  req.FullName,
-		Email:     req.Email,
-		CreatedAt: time.Now(),
-		Status:    registration.Status,
+		ID:                id,
+		EventID:           eventID,
+		FullName:          req.FullName,
+		Email:             req.Email,
+		Phone:             registration.Phone,
+		NotifyChannel:     string(registration.NotifyChannel),
+		CreatedAt:         time.Now(),
+		Status:            string(registration.Status),
+		WaitlistPosition:  registration.WaitlistPosition,
+		ExpiresAt:         registration.ExpiresAt,
+		ConfirmationToken: registration.ConfirmationToken,
+		ConfirmURL:        confirmURL,
+		PaymentURL:        paymentURL,
 	})
 }
 
@@ -200,51 +342,479 @@ func (s *service) Confirm(ctx *ginext.Context) {
 		return
 	}
 
-	reg, err := s.repo.GetRegistrationByRegID(ctx, req.RegID)
+	if event.IsPaid() {
+		dto.PaymentRequiredError(ctx, "Paid registrations are confirmed by the payment provider")
+		return
+	}
+
+	reg, err := s.repo.ConfirmByTokenTx(ctx.Request.Context(), eventID, req.Token)
+	if err != nil {
+		switch err {
+		case repo.ErrInvalidConfirmationToken, repo.ErrConfirmationTokenExpired:
+			s.tokenError(ctx, err)
+		default:
+			var transitionErr *model.InvalidTransitionError
+			if errors.As(err, &transitionErr) {
+				dto.InvalidStatusTransitionError(ctx, string(transitionErr.From), string(transitionErr.To))
+				return
+			}
+			s.log.Error().Err(err).Msg("failed to confirm registration")
+			dto.InternalServerError(ctx)
+		}
+		return
+	}
+
+	s.log.Info().
+		Int("registration_id", reg.ID).
+		Str("email", reg.Email).
+		Msg("registration confirmed successfully")
+	s.cancelExpiry(ctx.Request.Context(), int64(reg.ID))
+
+	dto.SuccessResponse(ctx, dto.RegistrationResponse{
+		ID:        int64(reg.ID),
+		EventID:   eventID,
+		FullName:  reg.FullName,
+		Email:     reg.Email,
+		Status:    string(reg.Status),
+		CreatedAt: reg.CreatedAt,
+		UpdatedAt: time.Now(),
+	})
+}
+
+// Checkout redirects the attendee to the provider's payment page for their pending registration,
+// creating the payment intent on first use.
+func (s *service) Checkout(ctx *ginext.Context) {
+	eventID, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		dto.BadResponseError(ctx, dto.FieldIncorrect, "Invalid event ID")
+		return
+	}
+	confirmationToken := ctx.Query("token")
+	if confirmationToken == "" {
+		dto.FieldIncorrectError(ctx, "token")
+		return
+	}
+
+	event, err := s.repo.GetEventByID(ctx, eventID)
 	if err != nil {
-		s.log.Error().Err(err).Msg("registration not found for confirmation")
-		dto.BadResponseError(ctx, dto.FieldIncorrect, "Registration not found")
+		dto.EventNotFoundError(ctx)
+		return
+	}
+	if !event.IsPaid() {
+		dto.BadResponseError(ctx, dto.PaymentNotRequired, "Event is free, no payment required")
 		return
 	}
-	if reg.Email != req.Email {
-		dto.BadResponseError(ctx, dto.FieldIncorrect, "Wrong email for this registration")
+
+	reg, err := s.repo.GetRegistrationByToken(ctx.Request.Context(), eventID, confirmationToken)
+	if err != nil {
+		s.tokenError(ctx, err)
 		return
 	}
 
-	if reg.Status == "confirmed" {
-		dto.BadResponseError(ctx, dto.FieldIncorrect, "Already confirmed")
+	p, err := s.repo.GetActivePaymentByRegistrationID(ctx.Request.Context(), int64(reg.ID))
+	switch {
+	case err == nil:
+		ctx.Redirect(http.StatusSeeOther, p.CheckoutURL)
+		return
+	case !errors.Is(err, repo.ErrPaymentNotFound):
+		s.log.Error().Err(err).Int("registration_id", reg.ID).Msg("failed to get payment")
+		dto.InternalServerError(ctx)
 		return
 	}
 
-	if reg.Status == "canceled" {
-		dto.BadResponseError(ctx, dto.FieldIncorrect, "Registration was canceled")
+	intent, err := s.payments.CreateIntent(ctx.Request.Context(), payment.CreateIntentRequest{
+		Reference:   fmt.Sprintf("registration:%d", reg.ID),
+		Amount:      event.Price,
+		Currency:    event.Currency,
+		Description: event.Name,
+		ReturnURL:   notify.PaymentReturnLink(s.publicURL, eventID),
+	})
+	if err != nil {
+		s.log.Error().Err(err).Int("registration_id", reg.ID).Msg("failed to create payment intent")
+		dto.BadGatewayError(ctx)
 		return
 	}
 
-	if err := s.repo.UpdateRegistrationStatusTx(ctx, int64(reg.ID), "confirmed"); err != nil {
-		s.log.Error().Err(err).Msg("failed to update registration status to confirmed")
+	p = &model.Payment{
+		RegistrationID:   int64(reg.ID),
+		Provider:         s.payments.Name(),
+		ProviderIntentID: intent.ID,
+		Amount:           intent.Amount,
+		Currency:         intent.Currency,
+		Status:           intent.Status,
+		CheckoutURL:      intent.CheckoutURL,
+	}
+	if _, err := s.repo.CreatePayment(ctx.Request.Context(), p); err != nil {
+		s.log.Error().Err(err).Int("registration_id", reg.ID).Msg("failed to store payment")
 		dto.InternalServerError(ctx)
 		return
 	}
 
 	s.log.Info().
 		Int("registration_id", reg.ID).
+		Str("intent_id", intent.ID).
+		Msg("payment intent created")
+
+	ctx.Redirect(http.StatusSeeOther, intent.CheckoutURL)
+}
+
+// PaymentWebhook receives provider notifications. Anything but a 2xx makes the provider retry,
+// so only failures worth retrying answer with an error.
+func (s *service) PaymentWebhook(ctx *ginext.Context) {
+	body, err := io.ReadAll(ctx.Request.Body)
+	if err != nil {
+		dto.BadResponseError(ctx, dto.FieldIncorrect, "Invalid request body")
+		return
+	}
+
+	wh, err := s.payments.VerifyWebhook(body, ctx.GetHeader(payment.SignatureHeader))
+	if err != nil {
+		s.log.Warn().Err(err).Msg("rejected payment webhook")
+		dto.UnauthorizedError(ctx)
+		return
+	}
+
+	log := s.log.With().Str("event_id", wh.ID).Str("type", wh.Type).Str("intent_id", wh.IntentID).Logger()
+
+	// An authorized payment still has to be collected; capture is idempotent, so a redelivered
+	// notification is harmless.
+	if wh.Type == payment.EventPaymentAuthorized {
+		intent, err := s.payments.Capture(ctx.Request.Context(), wh.IntentID)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to capture authorized payment")
+			dto.BadGatewayError(ctx)
+			return
+		}
+		if intent.Status != payment.StatusSucceeded {
+			log.Warn().Str("status", string(intent.Status)).Msg("captured payment has not succeeded")
+			dto.SuccessResponse(ctx, nil)
+			return
+		}
+		wh.Type = payment.EventPaymentSucceeded
+	}
+
+	result, err := s.repo.HandlePaymentEventTx(ctx.Request.Context(), s.payments.Name(), *wh)
+	switch {
+	case errors.Is(err, repo.ErrWebhookAlreadyProcessed):
+		log.Info().Msg("duplicate payment webhook ignored")
+		dto.SuccessResponse(ctx, nil)
+		return
+	case errors.Is(err, repo.ErrPaymentNotFound):
+		log.Warn().Msg("payment webhook for unknown intent")
+		dto.SuccessResponse(ctx, nil)
+		return
+	case err != nil:
+		log.Error().Err(err).Msg("failed to apply payment webhook")
+		dto.InternalServerError(ctx)
+		return
+	}
+
+	reg := result.Registration
+	log.Info().
+		Int("registration_id", reg.ID).
+		Str("payment_status", string(result.Payment.Status)).
+		Str("registration_status", string(reg.Status)).
+		Msg("payment webhook applied")
+
+	switch {
+	case reg.Status == model.StatusConfirmed && result.PreviousStatus == model.StatusPending:
+		s.cancelExpiry(ctx.Request.Context(), int64(reg.ID))
+	case result.Payment.Status == payment.StatusSucceeded && !reg.Status.HoldsSeat():
+		log.Warn().
+			Int("registration_id", reg.ID).
+			Msg("payment succeeded for a registration that no longer holds a seat, full refund scheduled")
+	}
+
+	dto.SuccessResponse(ctx, nil)
+}
+
+func (s *service) tokenError(ctx *ginext.Context, err error) {
+	switch err {
+	case repo.ErrInvalidConfirmationToken:
+		dto.BadResponseError(ctx, dto.ConfirmationTokenInvalid, "Confirmation token is invalid")
+	case repo.ErrConfirmationTokenExpired:
+		dto.BadResponseError(ctx, dto.ConfirmationTokenInvalid, "Confirmation token has expired")
+	default:
+		s.log.Error().Err(err).Msg("failed to look up confirmation token")
+		dto.InternalServerError(ctx)
+	}
+}
+
+func (s *service) CancelRegistration(ctx *ginext.Context) {
+	eventID, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		dto.BadResponseError(ctx, dto.FieldIncorrect, "Invalid event ID")
+		return
+	}
+	regID, err := strconv.ParseInt(ctx.Param("regId"), 10, 64)
+	if err != nil {
+		dto.BadResponseError(ctx, dto.FieldIncorrect, "Invalid registration ID")
+		return
+	}
+
+	var req dto.CancelRegistrationRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		dto.BadResponseError(ctx, dto.FieldIncorrect, "Invalid JSON format")
+		return
+	}
+
+	if verr := validator.Validate(ctx, req); verr != nil {
+		dto.BadResponseError(ctx, dto.FieldIncorrect, fmt.Sprintf("%v", verr))
+		return
+	}
+
+	reg, promoted, err := s.repo.CancelRegistrationTx(ctx.Request.Context(), eventID, regID, req.Email)
+	if err != nil {
+		switch err {
+		case repo.ErrEventNotFound:
+			dto.EventNotFoundError(ctx)
+		case repo.ErrRegistrationNotFound:
+			dto.RegistrationNotFoundError(ctx)
+		case repo.ErrRegistrationOwnerMismatch:
+			dto.RegistrationForbiddenError(ctx)
+		case repo.ErrCancellationDeadlinePassed:
+			dto.CancellationRejectedError(ctx, "Cancellation deadline has passed")
+		default:
+			var transitionErr *model.InvalidTransitionError
+			if errors.As(err, &transitionErr) {
+				dto.InvalidStatusTransitionError(ctx, string(transitionErr.From), string(transitionErr.To))
+				return
+			}
+			s.log.Error().Err(err).Msg("failed to cancel registration")
+			dto.InternalServerError(ctx)
+		}
+		return
+	}
+
+	s.log.Info().
+		Int64("registration_id", regID).
 		Str("email", reg.Email).
-		Msg("registration confirmed successfully")
+		Msg("registration canceled by attendee")
+	s.cancelExpiry(ctx.Request.Context(), regID)
+	if reg.Refund != nil {
+		s.log.Info().
+			Int64("registration_id", regID).
+			Int64("amount", reg.Refund.Amount).
+			Msg("refund scheduled")
+	}
 
-	if err := mailer.SendRegistrationEmail(s.log, event.Name, "confirmed", reg.Email, 0); err != nil {
-		zlog.Logger.Warn().Err(err).Msg("Failed to send successful registration notification on e-mail")
+	if promoted != nil {
+		s.logPromotion(promoted)
 	}
+
 	dto.SuccessResponse(ctx, dto.RegistrationResponse{
 		ID:        int64(reg.ID),
 		EventID:   eventID,
 		FullName:  reg.FullName,
 		Email:     reg.Email,
-		Status:    "confirmed",
+		Status:    string(reg.Status),
+		CreatedAt: reg.CreatedAt,
+		UpdatedAt: time.Now(),
+		Refund:    dto.RefundFromModel(reg.Refund),
+	})
+}
+
+// CancelRegistrationByAdmin cancels any registration of an event the caller manages. There is no
+// cancellation deadline here, and a paid registration is always refunded in full.
+func (s *service) CancelRegistrationByAdmin(ctx *ginext.Context) {
+	regID, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		dto.BadResponseError(ctx, dto.FieldIncorrect, "Invalid registration ID")
+		return
+	}
+
+	var req dto.AdminCancelRegistrationRequest
+	if ctx.Request.ContentLength > 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			dto.BadResponseError(ctx, dto.FieldIncorrect, "Invalid JSON format")
+			return
+		}
+	}
+
+	if verr := validator.Validate(ctx, req); verr != nil {
+		dto.BadResponseError(ctx, dto.FieldIncorrect, fmt.Sprintf("%v", verr))
+		return
+	}
+
+	existing, err := s.repo.GetRegistrationByID(ctx, regID)
+	if err != nil {
+		dto.RegistrationNotFoundError(ctx)
+		return
+	}
+	if !s.canManageEvent(ctx, int64(existing.EventID)) {
+		dto.ForbiddenError(ctx)
+		return
+	}
+
+	reason := req.Reason
+	if reason == "" {
+		reason = "canceled by organizer"
+	}
+
+	reg, promoted, err := s.repo.UpdateRegistrationStatusTx(ctx.Request.Context(), regID, model.StatusCanceled, model.ActorAdmin, reason)
+	if err != nil {
+		var transitionErr *model.InvalidTransitionError
+		switch {
+		case errors.Is(err, repo.ErrRegistrationNotFound):
+			dto.RegistrationNotFoundError(ctx)
+		case errors.As(err, &transitionErr):
+			dto.InvalidStatusTransitionError(ctx, string(transitionErr.From), string(transitionErr.To))
+		default:
+			s.log.Error().Err(err).Int64("registration_id", regID).Msg("failed to cancel registration")
+			dto.InternalServerError(ctx)
+		}
+		return
+	}
+
+	s.log.Info().
+		Int64("registration_id", regID).
+		Str("subject", auth.FromContext(ctx).Subject).
+		Msg("registration canceled by organizer")
+	s.cancelExpiry(ctx.Request.Context(), regID)
+
+	if promoted != nil {
+		s.logPromotion(promoted)
+	}
+
+	dto.SuccessResponse(ctx, dto.RegistrationResponse{
+		ID:        int64(reg.ID),
+		EventID:   int64(reg.EventID),
+		FullName:  reg.FullName,
+		Email:     reg.Email,
+		Status:    string(reg.Status),
+		CreatedAt: reg.CreatedAt,
 		UpdatedAt: time.Now(),
+		Refund:    dto.RefundFromModel(reg.Refund),
+	})
+}
+
+// ExtendRegistration gives a pending registration more time to pay. The expiry scheduled before
+// stays where it is but is ignored by the worker, since the registration's generation has moved on.
+func (s *service) ExtendRegistration(ctx *ginext.Context) {
+	regID, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		dto.BadResponseError(ctx, dto.FieldIncorrect, "Invalid registration ID")
+		return
+	}
+
+	var req dto.ExtendRegistrationRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		dto.BadResponseError(ctx, dto.FieldIncorrect, "Invalid JSON format")
+		return
+	}
+
+	if verr := validator.Validate(ctx, req); verr != nil {
+		dto.BadResponseError(ctx, dto.FieldIncorrect, fmt.Sprintf("%v", verr))
+		return
+	}
+
+	existing, err := s.repo.GetRegistrationByID(ctx, regID)
+	if err != nil {
+		dto.RegistrationNotFoundError(ctx)
+		return
+	}
+	if !s.canManageEvent(ctx, int64(existing.EventID)) {
+		dto.ForbiddenError(ctx)
+		return
+	}
+
+	reg, err := s.repo.ExtendExpiryTx(ctx.Request.Context(), regID, time.Duration(req.Minutes)*time.Minute, model.ActorAdmin)
+	if err != nil {
+		switch {
+		case errors.Is(err, repo.ErrRegistrationNotFound):
+			dto.RegistrationNotFoundError(ctx)
+		case errors.Is(err, repo.ErrRegistrationNotPending):
+			dto.RegistrationNotPendingError(ctx)
+		default:
+			s.log.Error().Err(err).Int64("registration_id", regID).Msg("failed to extend registration")
+			dto.InternalServerError(ctx)
+		}
+		return
+	}
+
+	s.log.Info().
+		Int64("registration_id", regID).
+		Int64("generation", reg.ExpiryGeneration).
+		Time("expires_at", *reg.ExpiresAt).
+		Str("subject", auth.FromContext(ctx).Subject).
+		Msg("registration payment deadline extended")
+
+	dto.SuccessResponse(ctx, dto.RegistrationResponse{
+		ID:        int64(reg.ID),
+		EventID:   int64(reg.EventID),
+		FullName:  reg.FullName,
+		Email:     reg.Email,
+		Status:    string(reg.Status),
+		CreatedAt: reg.CreatedAt,
+		UpdatedAt: reg.UpdatedAt,
+		ExpiresAt: reg.ExpiresAt,
 	})
 }
 
+func (s *service) GetRegistrationHistory(ctx *ginext.Context) {
+	regID, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		dto.BadResponseError(ctx, dto.FieldIncorrect, "Invalid registration ID")
+		return
+	}
+
+	reg, err := s.repo.GetRegistrationByID(ctx, regID)
+	if err != nil {
+		dto.RegistrationNotFoundError(ctx)
+		return
+	}
+	if !s.canManageEvent(ctx, int64(reg.EventID)) {
+		dto.ForbiddenError(ctx)
+		return
+	}
+
+	history, err := s.repo.GetRegistrationHistory(ctx, regID)
+	if err != nil {
+		s.log.Error().Err(err).Int64("registration_id", regID).Msg("failed to get registration history")
+		dto.InternalServerError(ctx)
+		return
+	}
+
+	resp := make([]dto.RegistrationHistoryResponse, 0, len(history))
+	for _, h := range history {
+		resp = append(resp, dto.RegistrationHistoryResponse{
+			ID:        h.ID,
+			OldStatus: string(h.OldStatus),
+			NewStatus: string(h.NewStatus),
+			Actor:     string(h.Actor),
+			Reason:    h.Reason,
+			CreatedAt: h.CreatedAt,
+		})
+	}
+
+	dto.SuccessResponse(ctx, resp)
+}
+
+func (s *service) canManageEvent(ctx *ginext.Context, eventID int64) bool {
+	event, err := s.repo.GetEventByID(ctx, eventID)
+	if err != nil {
+		return false
+	}
+	return auth.FromContext(ctx).CanManage(event.OrganizerID)
+}
+
+// cancelExpiry drops the scheduled expiry of a registration that has left the pending status.
+// It is best effort: a missed cancel only means the expiry handler later finds nothing to do.
+func (s *service) cancelExpiry(ctx context.Context, registrationID int64) {
+	if err := s.sched.Cancel(ctx, registrationID); err != nil {
+		s.log.Warn().Err(err).Int64("registration_id", registrationID).Msg("failed to cancel scheduled expiry")
+	}
+}
+
+// logPromotion records a waitlisted registration moved into a freed seat. Its notification has
+// already been queued together with the promotion.
+func (s *service) logPromotion(reg *model.Registration) {
+	s.log.Info().
+		Int("registration_id", reg.ID).
+		Int("event_id", reg.EventID).
+		Msg("waitlisted registration promoted to pending")
+}
+
 func (s *service) GetInfo(ctx *ginext.Context) {
 	eventID, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
 	if err != nil {
@@ -252,7 +822,7 @@ func (s *service) GetInfo(ctx *ginext.Context) {
 		return
 	}
 
-	isAdmin := ctx.Query("admin") == "true"
+	adminView := ctx.Query("admin") == "true"
 
 	event, err := s.repo.GetEventByID(ctx, eventID)
 	if err != nil {
@@ -260,6 +830,11 @@ func (s *service) GetInfo(ctx *ginext.Context) {
 		return
 	}
 
+	if adminView && !auth.FromContext(ctx).CanManage(event.OrganizerID) {
+		dto.ForbiddenError(ctx)
+		return
+	}
+
 	count, err := s.repo.CountRegistrations(ctx, eventID)
 	if err != nil {
 		s.log.Error().Err(err).Msg("failed to count registrations")
@@ -267,21 +842,37 @@ func (s *service) GetInfo(ctx *ginext.Context) {
 		return
 	}
 
+	waitlisted, err := s.repo.CountWaitlisted(ctx, eventID)
+	if err != nil {
+		s.log.Error().Err(err).Msg("failed to count waitlisted registrations")
+		dto.InternalServerError(ctx)
+		return
+	}
+
 	resp := dto.EventInfoResponse{
-		ID:                    int64(event.ID),
-		Name:                  event.Name,
-		Description:           event.Description,
-		StartTime:             event.StartTime,
-		EndTime:               event.EndTime,
-		Location:              event.Location,
-		Capacity:              event.Capacity,
-		PaymentTimeoutMinutes: event.PaymentTimeoutMinutes,
-		CreatedAt:             event.CreatedAt,
-		UpdatedAt:             event.UpdatedAt,
-		AvailableSeats:        event.Capacity - count,
-	}
-
-	if isAdmin {
+		ID:                        int64(event.ID),
+		Name:                      event.Name,
+		Description:               event.Description,
+		StartTime:                 event.StartTime,
+		EndTime:                   event.EndTime,
+		Location:                  event.Location,
+		Capacity:                  event.Capacity,
+		PaymentTimeoutMinutes:     event.PaymentTimeoutMinutes,
+		CancellationDeadlineHours: event.CancellationDeadlineHours,
+		Price:                     event.Price,
+		Currency:                  event.Currency,
+		RefundPolicy:              string(event.RefundPolicy),
+		RefundFullHours:           event.RefundFullHours,
+		RefundPartialPercent:      event.RefundPartialPercent,
+		CreatedAt:                 event.CreatedAt,
+		UpdatedAt:                 event.UpdatedAt,
+		AvailableSeats:            event.Capacity - count,
+		WaitlistLength:            waitlisted,
+	}
+
+	if adminView {
+		resp.OrganizerID = event.OrganizerID
+
 		registrations, err := s.repo.GetRegistrationsByEventID(ctx, eventID)
 		if err != nil {
 			s.log.Error().Err(err).Msg("failed to get registrations for admin view")
@@ -291,13 +882,15 @@ func (s *service) GetInfo(ctx *ginext.Context) {
 
 		for _, r := range registrations {
 			resp.Registrations = append(resp.Registrations, dto.RegistrationResponse{
-				ID:        int64(r.ID),
-				EventID:   int64(r.EventID),
-				FullName:  r.FullName,
-				Email:     r.Email,
-				Status:    r.Status,
-				CreatedAt: r.CreatedAt,
-				UpdatedAt: r.UpdatedAt,
+				ID:               int64(r.ID),
+				EventID:          int64(r.EventID),
+				FullName:         r.FullName,
+				Email:            r.Email,
+				Status:           string(r.Status),
+				CreatedAt:        r.CreatedAt,
+				UpdatedAt:        r.UpdatedAt,
+				WaitlistPosition: r.WaitlistPosition,
+				Refund:           dto.RefundFromModel(r.Refund),
 			})
 		}
 	}
@@ -306,48 +899,101 @@ func (s *service) GetInfo(ctx *ginext.Context) {
 }
 
 func (s *service) GetAllEvents(ctx *ginext.Context) {
-	isAdmin := ctx.Query("admin") == "true"
+	principal := auth.FromContext(ctx)
+	adminView := ctx.Query("admin") == "true"
+	if adminView && principal.Role != auth.RoleAdmin && principal.Role != auth.RoleOrganizer {
+		dto.ForbiddenError(ctx)
+		return
+	}
 
-	events, err := s.repo.GetAllEvents(ctx)
+	var req dto.ListEventsRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		dto.BadResponseError(ctx, dto.FieldIncorrect, "Invalid query parameters")
+		return
+	}
+	if verr := validator.Validate(ctx, req); verr != nil {
+		dto.BadResponseError(ctx, dto.FieldIncorrect, fmt.Sprintf("%v", verr))
+		return
+	}
+	if req.Limit == 0 {
+		req.Limit = defaultEventPageSize
+	}
+
+	page, err := s.repo.ListEvents(ctx, model.EventFilter{
+		StartFrom: req.From,
+		StartTo:   req.To,
+		Location:  req.Location,
+		HasSeats:  req.HasSeats,
+		Upcoming:  req.When == "upcoming",
+		Past:      req.When == "past",
+		Sort:      model.EventSort(req.Sort),
+		Limit:     req.Limit,
+		Cursor:    req.Cursor,
+	})
 	if err != nil {
+		if errors.Is(err, repo.ErrInvalidCursor) {
+			dto.BadResponseError(ctx, dto.FieldIncorrect, "Invalid cursor: it must come from a request with the same sort")
+			return
+		}
+		s.log.Error().Err(err).Msg("failed to list events")
 		dto.InternalServerError(ctx)
 		return
 	}
 
-	resp := make([]dto.EventInfoResponse, 0, len(events))
-
-	for _, e := range events {
-		count, err := s.repo.CountRegistrations(ctx, int64(e.ID))
+	// registrations of every event the caller manages, in one query
+	var registrations map[int64][]model.Registration
+	if adminView {
+		var managed []int64
+		for _, e := range page.Events {
+			if principal.CanManage(e.OrganizerID) {
+				managed = append(managed, int64(e.ID))
+			}
+		}
+		registrations, err = s.repo.GetRegistrationsByEventIDs(ctx, managed)
 		if err != nil {
-			s.log.Error().Err(err).Msg("failed to count registrations for event")
-			continue
+			s.log.Error().Err(err).Msg("failed to get registrations for admin view")
+			dto.InternalServerError(ctx)
+			return
 		}
+	}
 
+	resp := make([]dto.EventInfoResponse, 0, len(page.Events))
+
+	for _, e := range page.Events {
 		item := dto.EventInfoResponse{
-			ID:                    int64(e.ID),
-			Name:                  e.Name,
-			Description:           e.Description,
-			StartTime:             e.StartTime,
-			EndTime:               e.EndTime,
-			Location:              e.Location,
-			Capacity:              e.Capacity,
-			AvailableSeats:        e.Capacity - count,
-			PaymentTimeoutMinutes: e.PaymentTimeoutMinutes,
-			CreatedAt:             e.CreatedAt,
-			UpdatedAt:             e.UpdatedAt,
+			ID:                        int64(e.ID),
+			Name:                      e.Name,
+			Description:               e.Description,
+			StartTime:                 e.StartTime,
+			EndTime:                   e.EndTime,
+			Location:                  e.Location,
+			Capacity:                  e.Capacity,
+			AvailableSeats:            e.Capacity - e.Taken,
+			WaitlistLength:            e.Waitlisted,
+			PaymentTimeoutMinutes:     e.PaymentTimeoutMinutes,
+			CancellationDeadlineHours: e.CancellationDeadlineHours,
+			Price:                     e.Price,
+			Currency:                  e.Currency,
+			RefundPolicy:              string(e.RefundPolicy),
+			RefundFullHours:           e.RefundFullHours,
+			RefundPartialPercent:      e.RefundPartialPercent,
+			CreatedAt:                 e.CreatedAt,
+			UpdatedAt:                 e.UpdatedAt,
 		}
 
-		if isAdmin {
-			registrations, _ := s.repo.GetRegistrationsByEventID(ctx, int64(e.ID))
-			for _, r := range registrations {
+		if adminView && principal.CanManage(e.OrganizerID) {
+			item.OrganizerID = e.OrganizerID
+			for _, r := range registrations[int64(e.ID)] {
 				item.Registrations = append(item.Registrations, dto.RegistrationResponse{
-					ID:        int64(r.ID),
-					EventID:   int64(r.EventID),
-					FullName:  r.FullName,
-					Email:     r.Email,
-					Status:    r.Status,
-					CreatedAt: r.CreatedAt,
-					UpdatedAt: r.UpdatedAt,
+					ID:               int64(r.ID),
+					EventID:          int64(r.EventID),
+					FullName:         r.FullName,
+					Email:            r.Email,
+					Status:           string(r.Status),
+					CreatedAt:        r.CreatedAt,
+					UpdatedAt:        r.UpdatedAt,
+					WaitlistPosition: r.WaitlistPosition,
+					Refund:           dto.RefundFromModel(r.Refund),
 				})
 			}
 		}
@@ -355,5 +1001,5 @@ func (s *service) GetAllEvents(ctx *ginext.Context) {
 		resp = append(resp, item)
 	}
 
-	dto.SuccessResponse(ctx, resp)
+	dto.SuccessPageResponse(ctx, resp, dto.Meta{Total: page.Total, Limit: req.Limit, NextCursor: page.NextCursor})
 }
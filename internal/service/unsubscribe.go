@@ -0,0 +1,127 @@
+package service
+
+import (
+	"html/template"
+	"net/http"
+	"strings"
+
+	"fifthOne/internal/dto"
+	"fifthOne/internal/model"
+	"fifthOne/internal/notify"
+
+	"github.com/wb-go/wbf/ginext"
+)
+
+// UnsubscribePage answers people following an unsubscribe link. It changes nothing and only asks
+// to confirm: link scanners and prefetching mail clients open GET links on their own.
+func (s *service) UnsubscribePage(ctx *ginext.Context) {
+	category, _, ok := s.unsubscribeLink(ctx)
+	if !ok {
+		renderUnsubscribe(ctx, http.StatusBadRequest, unsubscribeView{Invalid: true})
+		return
+	}
+	renderUnsubscribe(ctx, http.StatusOK, unsubscribeView{
+		Category: unsubscribeLabel(category),
+		Action:   ctx.Request.URL.RequestURI(),
+	})
+}
+
+// Unsubscribe opts the contact of a signed link out of its category, or of every optional
+// category for "all". Mail clients POST here for RFC 8058 one-click unsubscribe, and so does the
+// form of UnsubscribePage; a browser gets a page back, anything else JSON.
+func (s *service) Unsubscribe(ctx *ginext.Context) {
+	category, categories, ok := s.unsubscribeLink(ctx)
+	if !ok {
+		dto.UnsubscribeLinkInvalidError(ctx)
+		return
+	}
+
+	prefs, err := s.repo.OptOut(ctx.Request.Context(), ctx.Query("contact"), categories...)
+	if err != nil {
+		s.log.Error().Err(err).Msg("failed to store opt-out")
+		dto.InternalServerError(ctx)
+		return
+	}
+
+	s.log.Info().Msgf("Contact %s unsubscribed from %s notifications", prefs.Contact, category)
+	if strings.Contains(ctx.GetHeader("Accept"), "text/html") {
+		renderUnsubscribe(ctx, http.StatusOK, unsubscribeView{Category: unsubscribeLabel(category), Done: true})
+		return
+	}
+	dto.SuccessResponse(ctx, prefs)
+}
+
+// unsubscribeLink checks the signature of the link in the query and returns its category and the
+// categories to opt out of.
+func (s *service) unsubscribeLink(ctx *ginext.Context) (string, []model.NotificationCategory, bool) {
+	contact := ctx.Query("contact")
+	category := ctx.Query("category")
+	if contact == "" || category == "" || !s.unsubscribe.Valid(contact, category, ctx.Query("sig")) {
+		return "", nil, false
+	}
+
+	if category == notify.UnsubscribeAll {
+		return category, model.OptionalCategories, true
+	}
+	c := model.NotificationCategory(category)
+	if !c.Optional() {
+		return "", nil, false
+	}
+	return category, []model.NotificationCategory{c}, true
+}
+
+func unsubscribeLabel(category string) string {
+	switch model.NotificationCategory(category) {
+	case model.CategoryReminders:
+		return "напоминаний о мероприятиях"
+	case model.CategoryMarketing:
+		return "рекламных рассылок"
+	case model.CategoryWaitlistOffers:
+		return "предложений из листа ожидания"
+	}
+	return "всех необязательных рассылок"
+}
+
+type unsubscribeView struct {
+	Category string
+	// Action is the signed link itself; the form posts back to it.
+	Action  string
+	Done    bool
+	Invalid bool
+}
+
+func renderUnsubscribe(ctx *ginext.Context, status int, view unsubscribeView) {
+	ctx.Header("Content-Type", "text/html; charset=utf-8")
+	ctx.Status(status)
+	_ = unsubscribeTmpl.Execute(ctx.Writer, view)
+}
+
+var unsubscribeTmpl = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html lang="ru">
+<head>
+    <meta charset="UTF-8">
+    <title>Отписка от рассылки</title>
+    <style>
+        body { font-family: Arial, sans-serif; max-width: 480px; margin: 40px auto; }
+        .card { border: 1px solid #ccc; padding: 20px; border-radius: 8px; }
+        button { margin-top: 10px; padding: 8px 16px; }
+    </style>
+</head>
+<body>
+<div class="card">
+    <h2>EventBooker</h2>
+    {{if .Invalid}}
+    <p>Ссылка для отписки недействительна.</p>
+    {{else if .Done}}
+    <p>Готово: вы отписались от {{.Category}}. Письма о ваших бронях по-прежнему будут приходить.</p>
+    {{else}}
+    <p>Отписаться от {{.Category}}? Письма о ваших бронях по-прежнему будут приходить.</p>
+    <form method="post" action="{{.Action}}">
+        <input type="hidden" name="List-Unsubscribe" value="One-Click">
+        <button type="submit">Отписаться</button>
+    </form>
+    {{end}}
+</div>
+</body>
+</html>
+`))
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"fifthOne/internal/model"
+	"fifthOne/internal/notify"
+	"fifthOne/internal/repo"
+
+	"github.com/rs/zerolog"
+	"github.com/wb-go/wbf/ginext"
+)
+
+// optOutRepo records opt-outs; nothing else is expected to be called.
+type optOutRepo struct {
+	repo.Repository
+	optOuts [][]model.NotificationCategory
+}
+
+func (r *optOutRepo) OptOut(_ context.Context, contact string, categories ...model.NotificationCategory) (*model.ContactPreferences, error) {
+	r.optOuts = append(r.optOuts, categories)
+	return &model.ContactPreferences{Contact: model.NormalizeContact(contact)}, nil
+}
+
+func newUnsubscribeRouter(t *testing.T) (*ginext.Engine, *optOutRepo, *notify.Unsubscribe) {
+	t.Helper()
+	store := &optOutRepo{}
+	unsubscribe := notify.NewUnsubscribe("https://eventbooker.example", "secret")
+	log := zerolog.Nop()
+	s := NewService(store, &log, nil, nil, unsubscribe, nil, "https://eventbooker.example")
+
+	router := ginext.New("release")
+	router.GET("/v1/unsubscribe", s.UnsubscribePage)
+	router.POST("/v1/unsubscribe", s.Unsubscribe)
+	return router, store, unsubscribe
+}
+
+func unsubscribePath(t *testing.T, link string) string {
+	t.Helper()
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.RequestURI()
+}
+
+func TestUnsubscribeGetOnlyAsks(t *testing.T) {
+	router, store, unsubscribe := newUnsubscribeRouter(t)
+	path := unsubscribePath(t, unsubscribe.Link("a@example.com", model.CategoryReminders))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d", w.Code)
+	}
+	if len(store.optOuts) != 0 {
+		t.Fatalf("GET recorded an opt-out: %v", store.optOuts)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, `method="post"`) || !strings.Contains(body, "напоминаний") {
+		t.Errorf("page has no confirmation form:\n%s", body)
+	}
+}
+
+func TestUnsubscribePostOptsOut(t *testing.T) {
+	router, store, unsubscribe := newUnsubscribeRouter(t)
+	path := unsubscribePath(t, unsubscribe.Link("a@example.com", model.CategoryTransactional))
+
+	// RFC 8058: the mail client posts List-Unsubscribe=One-Click to the link
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader("List-Unsubscribe=One-Click"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	if len(store.optOuts) != 1 || len(store.optOuts[0]) != len(model.OptionalCategories) {
+		t.Fatalf("opt-outs %v, want every optional category once", store.optOuts)
+	}
+}
+
+func TestUnsubscribeRejectsForgedLink(t *testing.T) {
+	router, store, unsubscribe := newUnsubscribeRouter(t)
+	path := unsubscribePath(t, unsubscribe.Link("a@example.com", model.CategoryReminders))
+	forged := strings.Replace(path, "a%40example.com", "b%40example.com", 1)
+
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, forged, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", method, w.Code)
+		}
+	}
+	if len(store.optOuts) != 0 {
+		t.Fatalf("forged link recorded an opt-out: %v", store.optOuts)
+	}
+}
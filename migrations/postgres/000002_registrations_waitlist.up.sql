@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS idx_registrations_event_status ON registrations (event_id, status, id);
@@ -0,0 +1,3 @@
+ALTER TABLE events
+    ADD COLUMN IF NOT EXISTS cancellation_deadline_hours INT NOT NULL DEFAULT 0
+        CHECK (cancellation_deadline_hours >= 0);
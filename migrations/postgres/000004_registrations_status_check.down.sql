@@ -0,0 +1,6 @@
+ALTER TABLE registrations
+    DROP CONSTRAINT IF EXISTS registrations_status_check,
+    ALTER COLUMN status DROP NOT NULL;
+
+UPDATE registrations SET status = 'canceled' WHERE status = 'expired';
+UPDATE registrations SET status = 'confirmed' WHERE status = 'checked_in';
@@ -0,0 +1,6 @@
+UPDATE registrations SET status = 'pending' WHERE status IS NULL;
+
+ALTER TABLE registrations
+    ALTER COLUMN status SET NOT NULL,
+    ADD CONSTRAINT registrations_status_check
+        CHECK (status IN ('pending', 'confirmed', 'canceled', 'expired', 'waitlisted', 'checked_in'));
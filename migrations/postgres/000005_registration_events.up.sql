@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS registration_events (
+                                                   id BIGSERIAL PRIMARY KEY,
+                                                   registration_id INT NOT NULL REFERENCES registrations(id) ON DELETE CASCADE,
+                                                   old_status VARCHAR(50),
+                                                   new_status VARCHAR(50) NOT NULL,
+                                                   actor VARCHAR(20) NOT NULL CHECK (actor IN ('user', 'admin', 'worker')),
+                                                   reason TEXT NOT NULL DEFAULT '',
+                                                   created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_registration_events_registration ON registration_events (registration_id, created_at);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_events_organizer;
+ALTER TABLE events DROP COLUMN IF EXISTS organizer_id;
@@ -0,0 +1,3 @@
+ALTER TABLE events ADD COLUMN IF NOT EXISTS organizer_id VARCHAR(255);
+
+CREATE INDEX IF NOT EXISTS idx_events_organizer ON events (organizer_id);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_registrations_confirmation_token;
+
+ALTER TABLE registrations
+    DROP COLUMN IF EXISTS token_expires_at,
+    DROP COLUMN IF EXISTS confirmation_token_hash;
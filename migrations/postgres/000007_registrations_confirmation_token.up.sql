@@ -0,0 +1,7 @@
+ALTER TABLE registrations
+    ADD COLUMN IF NOT EXISTS confirmation_token_hash VARCHAR(64),
+    ADD COLUMN IF NOT EXISTS token_expires_at TIMESTAMP;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_registrations_confirmation_token
+    ON registrations (confirmation_token_hash)
+    WHERE confirmation_token_hash IS NOT NULL;
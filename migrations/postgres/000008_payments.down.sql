@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS payments;
+
+ALTER TABLE events
+    DROP COLUMN IF EXISTS currency,
+    DROP COLUMN IF EXISTS price;
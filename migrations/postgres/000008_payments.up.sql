@@ -0,0 +1,20 @@
+ALTER TABLE events
+    ADD COLUMN IF NOT EXISTS price BIGINT NOT NULL DEFAULT 0 CHECK (price >= 0),
+    ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'RUB';
+
+CREATE TABLE IF NOT EXISTS payments (
+    id SERIAL PRIMARY KEY,
+    registration_id INT NOT NULL REFERENCES registrations(id) ON DELETE CASCADE,
+    provider VARCHAR(50) NOT NULL,
+    provider_intent_id VARCHAR(255) NOT NULL,
+    amount BIGINT NOT NULL CHECK (amount > 0),
+    currency CHAR(3) NOT NULL,
+    status VARCHAR(50) NOT NULL
+        CHECK (status IN ('requires_payment', 'authorized', 'succeeded', 'failed', 'refunded')),
+    checkout_url TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (provider, provider_intent_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_payments_registration ON payments (registration_id);
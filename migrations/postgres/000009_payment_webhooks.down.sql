@@ -0,0 +1,15 @@
+UPDATE registration_events SET actor = 'worker' WHERE actor = 'provider';
+
+ALTER TABLE registration_events
+    DROP CONSTRAINT IF EXISTS registration_events_actor_check,
+    ADD CONSTRAINT registration_events_actor_check
+        CHECK (actor IN ('user', 'admin', 'worker'));
+
+UPDATE registrations SET status = 'canceled' WHERE status = 'refunded';
+
+ALTER TABLE registrations
+    DROP CONSTRAINT IF EXISTS registrations_status_check,
+    ADD CONSTRAINT registrations_status_check
+        CHECK (status IN ('pending', 'confirmed', 'canceled', 'expired', 'waitlisted', 'checked_in'));
+
+DROP TABLE IF EXISTS processed_webhooks;
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS processed_webhooks (
+    provider VARCHAR(50) NOT NULL,
+    event_id VARCHAR(255) NOT NULL,
+    event_type VARCHAR(100) NOT NULL,
+    processed_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (provider, event_id)
+);
+
+ALTER TABLE registrations
+    DROP CONSTRAINT IF EXISTS registrations_status_check,
+    ADD CONSTRAINT registrations_status_check
+        CHECK (status IN ('pending', 'confirmed', 'canceled', 'expired', 'waitlisted', 'checked_in', 'refunded'));
+
+ALTER TABLE registration_events
+    DROP CONSTRAINT IF EXISTS registration_events_actor_check,
+    ADD CONSTRAINT registration_events_actor_check
+        CHECK (actor IN ('user', 'admin', 'worker', 'provider'));
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS refunds;
+
+ALTER TABLE events
+    DROP COLUMN IF EXISTS refund_partial_percent,
+    DROP COLUMN IF EXISTS refund_full_hours,
+    DROP COLUMN IF EXISTS refund_policy;
@@ -0,0 +1,27 @@
+ALTER TABLE events
+    ADD COLUMN IF NOT EXISTS refund_policy VARCHAR(20) NOT NULL DEFAULT 'full'
+        CHECK (refund_policy IN ('none', 'full', 'tiered')),
+    ADD COLUMN IF NOT EXISTS refund_full_hours INT NOT NULL DEFAULT 0 CHECK (refund_full_hours >= 0),
+    ADD COLUMN IF NOT EXISTS refund_partial_percent INT NOT NULL DEFAULT 0
+        CHECK (refund_partial_percent BETWEEN 0 AND 100);
+
+CREATE TABLE IF NOT EXISTS refunds (
+    id SERIAL PRIMARY KEY,
+    registration_id INT NOT NULL REFERENCES registrations(id) ON DELETE CASCADE,
+    payment_id INT NOT NULL REFERENCES payments(id) ON DELETE CASCADE,
+    amount BIGINT NOT NULL CHECK (amount > 0),
+    currency CHAR(3) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'succeeded', 'failed')),
+    reason TEXT NOT NULL DEFAULT '',
+    provider_refund_id VARCHAR(255),
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- a payment is refunded at most once
+CREATE UNIQUE INDEX IF NOT EXISTS idx_refunds_payment ON refunds (payment_id);
+CREATE INDEX IF NOT EXISTS idx_refunds_registration ON refunds (registration_id);
+CREATE INDEX IF NOT EXISTS idx_refunds_due ON refunds (next_attempt_at) WHERE status = 'pending';
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_registrations_pending_expiry;
+
+ALTER TABLE registrations
+    DROP COLUMN IF EXISTS expires_at;
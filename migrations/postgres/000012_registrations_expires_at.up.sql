@@ -0,0 +1,10 @@
+ALTER TABLE registrations
+    ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP;
+
+UPDATE registrations
+SET expires_at = token_expires_at
+WHERE status = 'pending' AND expires_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_registrations_pending_expiry
+    ON registrations (expires_at)
+    WHERE status = 'pending';
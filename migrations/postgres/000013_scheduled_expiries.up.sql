@@ -0,0 +1,8 @@
+-- used by the postgres expiry scheduler (scheduler.kind: postgres)
+CREATE TABLE IF NOT EXISTS scheduled_expiries (
+    registration_id INT PRIMARY KEY REFERENCES registrations(id) ON DELETE CASCADE,
+    run_at TIMESTAMP NOT NULL,
+    locked_until TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_scheduled_expiries_run_at ON scheduled_expiries (run_at);
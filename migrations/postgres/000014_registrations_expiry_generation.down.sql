@@ -0,0 +1,5 @@
+ALTER TABLE scheduled_expiries
+    DROP COLUMN IF EXISTS generation;
+
+ALTER TABLE registrations
+    DROP COLUMN IF EXISTS expiry_generation;
@@ -0,0 +1,7 @@
+-- bumped every time a pending registration gets a new expiry deadline; scheduled expiries carry
+-- the generation they were created for and are ignored once it has moved on
+ALTER TABLE registrations
+    ADD COLUMN IF NOT EXISTS expiry_generation INT NOT NULL DEFAULT 0;
+
+ALTER TABLE scheduled_expiries
+    ADD COLUMN IF NOT EXISTS generation INT NOT NULL DEFAULT 0;
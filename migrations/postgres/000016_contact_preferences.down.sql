@@ -0,0 +1,6 @@
+UPDATE notifications SET status = 'failed' WHERE status = 'skipped';
+ALTER TABLE notifications DROP CONSTRAINT IF EXISTS notifications_status_check;
+ALTER TABLE notifications
+    ADD CONSTRAINT notifications_status_check CHECK (status IN ('pending', 'sent', 'failed'));
+
+DROP TABLE IF EXISTS contact_preferences;
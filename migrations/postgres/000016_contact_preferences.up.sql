@@ -0,0 +1,13 @@
+-- opt-outs per email address or phone number; transactional notifications ignore them
+CREATE TABLE IF NOT EXISTS contact_preferences (
+    contact VARCHAR(255) PRIMARY KEY,
+    opt_out_reminders BOOLEAN NOT NULL DEFAULT FALSE,
+    opt_out_marketing BOOLEAN NOT NULL DEFAULT FALSE,
+    opt_out_waitlist_offers BOOLEAN NOT NULL DEFAULT FALSE,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- skipped: not sent because the recipient opted out
+ALTER TABLE notifications DROP CONSTRAINT IF EXISTS notifications_status_check;
+ALTER TABLE notifications
+    ADD CONSTRAINT notifications_status_check CHECK (status IN ('pending', 'sent', 'failed', 'skipped'));
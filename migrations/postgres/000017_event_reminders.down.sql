@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_events_start_time;
+DROP TABLE IF EXISTS event_reminders;
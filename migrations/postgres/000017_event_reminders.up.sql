@@ -0,0 +1,12 @@
+-- reminders already queued, so a restarted worker does not send them again; start_time is the
+-- start the reminder was for, a rescheduled event gets its reminders anew
+CREATE TABLE IF NOT EXISTS event_reminders (
+    registration_id INT NOT NULL REFERENCES registrations(id) ON DELETE CASCADE,
+    start_time TIMESTAMP NOT NULL,
+    offset_seconds INT NOT NULL,
+    notification_id BIGINT REFERENCES notifications(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (registration_id, start_time, offset_seconds)
+);
+
+CREATE INDEX IF NOT EXISTS idx_events_start_time ON events (start_time);
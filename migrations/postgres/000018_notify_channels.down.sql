@@ -0,0 +1,3 @@
+DELETE FROM notifications WHERE channel = 'sms';
+ALTER TABLE notifications DROP COLUMN IF EXISTS channel;
+ALTER TABLE registrations DROP COLUMN IF EXISTS notify_channel;
@@ -0,0 +1,9 @@
+-- where the attendee wants notifications: email, sms or both
+ALTER TABLE registrations
+    ADD COLUMN IF NOT EXISTS notify_channel VARCHAR(10) NOT NULL DEFAULT 'email'
+        CHECK (notify_channel IN ('email', 'sms', 'both'));
+
+-- how a queued notification goes out; recipient is an email address or an E.164 phone number
+ALTER TABLE notifications
+    ADD COLUMN IF NOT EXISTS channel VARCHAR(10) NOT NULL DEFAULT 'email'
+        CHECK (channel IN ('email', 'sms'));
@@ -0,0 +1,2 @@
+-- the removed links cannot be restored
+SELECT 1;
@@ -0,0 +1,3 @@
+-- links with plain confirmation tokens queued before they were sealed; the attendee still gets the
+-- message, without the link, and can confirm from the booking page
+UPDATE notifications SET payload = payload - 'link' WHERE payload ? 'link';
@@ -0,0 +1,57 @@
+package token
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSealOpen(t *testing.T) {
+	const plain = "https://eventbooker.example/user?event=1&token=secret-token"
+
+	sealed, err := Seal("key", plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(sealed, "secret-token") {
+		t.Fatalf("sealed value shows the plain text: %s", sealed)
+	}
+	again, err := Seal("key", plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again == sealed {
+		t.Error("sealing twice gave the same value")
+	}
+
+	got, err := Open("key", sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != plain {
+		t.Errorf("Open = %q, want %q", got, plain)
+	}
+}
+
+func TestOpenRejects(t *testing.T) {
+	sealed, err := Seal("key", "plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := []byte(sealed)
+	tampered[10] ^= 1
+
+	for name, tt := range map[string]struct{ secret, sealed string }{
+		"other secret": {"other", sealed},
+		"tampered":     {"key", string(tampered)},
+		"not base64":   {"key", "%%%"},
+		"too short":    {"key", "AA"},
+		"empty":        {"key", ""},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Open(tt.secret, tt.sealed); !errors.Is(err, ErrSealed) {
+				t.Fatalf("got %v, want ErrSealed", err)
+			}
+		})
+	}
+}
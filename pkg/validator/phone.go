@@ -0,0 +1,48 @@
+package validator
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/go-playground/validator"
+)
+
+var (
+	e164Regex = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+	// phoneFormatting is stripped before a number is normalized
+	phoneFormatting = strings.NewReplacer(" ", "", "-", "", "(", "", ")", "", ".", "", "\u00a0", "")
+)
+
+var ErrInvalidPhone = errors.New("invalid phone number")
+
+// NormalizePhone converts a phone number as people type it into E.164, e.g. "8 (999) 123-45-67"
+// into "+79991234567". Numbers without a country code are taken as Russian: a leading 8 is the
+// trunk prefix, and ten digits are a number without it.
+func NormalizePhone(phone string) (string, error) {
+	p := phoneFormatting.Replace(strings.TrimSpace(phone))
+
+	switch {
+	case strings.HasPrefix(p, "+"):
+	case strings.HasPrefix(p, "00"):
+		p = "+" + p[2:]
+	case len(p) == 11 && strings.HasPrefix(p, "8"):
+		p = "+7" + p[1:]
+	case len(p) == 11 && strings.HasPrefix(p, "7"):
+		p = "+" + p
+	case len(p) == 10:
+		p = "+7" + p
+	default:
+		return "", ErrInvalidPhone
+	}
+
+	if !e164Regex.MatchString(p) {
+		return "", ErrInvalidPhone
+	}
+	return p, nil
+}
+
+func validatePhone(fl validator.FieldLevel) bool {
+	_, err := NormalizePhone(fl.Field().String())
+	return err == nil
+}
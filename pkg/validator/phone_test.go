@@ -0,0 +1,63 @@
+package validator
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalizePhone(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"E.164", "+79991234567", "+79991234567"},
+		{"leading 8 is the trunk prefix", "89991234567", "+79991234567"},
+		{"leading 8 with formatting", "8 (999) 123-45-67", "+79991234567"},
+		{"spaces", "+7 999 123 45 67", "+79991234567"},
+		{"dashes", "+7-999-123-45-67", "+79991234567"},
+		{"parentheses", "+7(999)1234567", "+79991234567"},
+		{"dots and a non-breaking space", "+7\u00a0999.123.45.67", "+79991234567"},
+		{"surrounding whitespace", "  +79991234567\t", "+79991234567"},
+		{"missing plus before 7", "79991234567", "+79991234567"},
+		{"missing plus and country code", "9991234567", "+79991234567"},
+		{"00 international prefix", "00 49 151 23456789", "+4915123456789"},
+		{"other country", "+44 20 7946 0958", "+442079460958"},
+		{"shortest E.164", "+1234567", "+1234567"},
+		{"longest E.164", "+123456789012345", "+123456789012345"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizePhone(tt.in)
+			if err != nil {
+				t.Fatalf("NormalizePhone(%q): %v", tt.in, err)
+			}
+			if got != tt.want {
+				t.Errorf("NormalizePhone(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizePhoneRejects(t *testing.T) {
+	tests := []struct {
+		name, in string
+	}{
+		{"empty", ""},
+		{"only formatting", " (-) "},
+		{"too short", "+123456"},
+		{"too short without plus", "999123"},
+		{"too long", "+1234567890123456"},
+		{"too long without plus", "899912345678"},
+		{"missing plus before a foreign number", "4915123456789"},
+		{"country code 0", "+0123456789"},
+		{"letters", "+7999CALLNOW"},
+		{"two plus signs", "++79991234567"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizePhone(tt.in)
+			if !errors.Is(err, ErrInvalidPhone) {
+				t.Fatalf("NormalizePhone(%q) = %q, %v; want ErrInvalidPhone", tt.in, got, err)
+			}
+		})
+	}
+}
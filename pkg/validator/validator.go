@@ -33,6 +33,7 @@ func New() *validator.Validate {
 	_ = v.RegisterValidation("tag", validateTag)
 	_ = v.RegisterValidation("future", validateFutureDate)
 	_ = v.RegisterValidation("positive", validatePositiveInt)
+	_ = v.RegisterValidation("phone", validatePhone)
 	return v
 }
 
@@ -89,6 +90,8 @@ func parseValidationErrors(err error) error {
 		msg = "Date must be in the future"
 	case "positive":
 		msg = "Value must be positive"
+	case "phone":
+		msg = "Phone must be a valid phone number"
 	default:
 		msg = ErrUnknownValidation
 	}